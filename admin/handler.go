@@ -0,0 +1,376 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+//
+// DefaultShutdownTimeout is how long a graceful shutdown triggered through the admin API may take
+// if no other timeout has been configured.
+//
+const DefaultShutdownTimeout = 30 * time.Second
+
+//
+// DefaultMaxBroadcastSize is the largest message that may be broadcast through the admin API if no
+// other limit has been configured.
+//
+const DefaultMaxBroadcastSize = 64 * 1024
+
+//
+// HandlerConfig holds various configuration attributes for creating a new admin API handler.
+//
+type HandlerConfig struct {
+	Server           *tcp.Server   // The server to inspect and control. Servers of the other transports expose theirs by embedding it.
+	ShutdownTimeout  time.Duration // How long a graceful shutdown triggered through the API may take. Defaults to DefaultShutdownTimeout.
+	MaxBroadcastSize int           // The largest message that may be broadcast through the API, in bytes. Defaults to DefaultMaxBroadcastSize.
+}
+
+//
+// Handler is an http.Handler that serves a small JSON API for inspecting and controlling a running
+// server. It is intended to be mounted (e.g. with http.StripPrefix) on an internal-only listener,
+// as it performs no authentication of its own. The following routes are served:
+//
+//   GET  /state              The server's lifecycle state, bound address, and client count.
+//   GET  /clients            Every connected client, with its statistics and attributes.
+//   GET  /clients/{id}       A single connected client.
+//   POST /clients/{id}/kick  Kicks a client. The optional "reason" query parameter is logged.
+//   POST /broadcast          Sends the request body as a message to every connected client.
+//   POST /shutdown           Begins a graceful shutdown of the server.
+//
+type Handler struct {
+	config *HandlerConfig // Basic configuration attributes of the handler.
+}
+
+//
+// CreateHandler creates a new admin API handler for the configured server.
+//
+func CreateHandler(config *HandlerConfig) (*Handler, error) {
+	if config.Server == nil {
+		return nil, errors.New("a server must be specified")
+	}
+
+	if config.ShutdownTimeout < 0 || config.MaxBroadcastSize < 0 {
+		return nil, errors.New("the shutdown timeout and maximum broadcast size must not be negative")
+	}
+
+	return &Handler{config: config}, nil
+}
+
+//
+// ServeHTTP implements the method described by the http.Handler interface.
+//
+func (o *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "state":
+		o.allow(w, r, http.MethodGet, o.serveState)
+
+	case len(parts) == 1 && parts[0] == "clients":
+		o.allow(w, r, http.MethodGet, o.serveClients)
+
+	case len(parts) == 2 && parts[0] == "clients":
+		o.allow(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			o.serveClient(w, r, parts[1])
+		})
+
+	case len(parts) == 3 && parts[0] == "clients" && parts[2] == "kick":
+		o.allow(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+			o.serveKick(w, r, parts[1])
+		})
+
+	case len(parts) == 1 && parts[0] == "broadcast":
+		o.allow(w, r, http.MethodPost, o.serveBroadcast)
+
+	case len(parts) == 1 && parts[0] == "shutdown":
+		o.allow(w, r, http.MethodPost, o.serveShutdown)
+
+	default:
+		writeError(w, http.StatusNotFound, "no such route")
+	}
+}
+
+//
+// stateView is the JSON representation of a server's state.
+//
+type stateView struct {
+	State   string `json:"state"`
+	Address string `json:"address,omitempty"`
+	Clients int    `json:"clients"`
+}
+
+//
+// clientView is the JSON representation of a single client.
+//
+type clientView struct {
+	ID            int                    `json:"id"`
+	Description   string                 `json:"description"`
+	RemoteAddr    string                 `json:"remote_addr"`
+	RTT           string                 `json:"rtt,omitempty"`
+	Groups        []string               `json:"groups"`
+	Subscriptions []string               `json:"subscriptions"`
+	Attributes    map[string]interface{} `json:"attributes"`
+	Stats         statsView              `json:"stats"`
+}
+
+//
+// statsView is the JSON representation of a client's traffic statistics.
+//
+type statsView struct {
+	ConnectedAt      time.Time  `json:"connected_at"`
+	LastRead         *time.Time `json:"last_read,omitempty"`
+	LastWrite        *time.Time `json:"last_write,omitempty"`
+	MessagesReceived uint64     `json:"messages_received"`
+	BytesReceived    uint64     `json:"bytes_received"`
+	MessagesSent     uint64     `json:"messages_sent"`
+	BytesSent        uint64     `json:"bytes_sent"`
+	SendFailures     uint64     `json:"send_failures"`
+	QueueLen         int        `json:"queue_len"`
+}
+
+//
+// serveState writes the server's lifecycle state, bound address, and client count.
+//
+func (o *Handler) serveState(w http.ResponseWriter, r *http.Request) {
+	view := stateView{
+		State:   o.config.Server.State().String(),
+		Clients: o.config.Server.ClientCount(),
+	}
+
+	if addr := o.config.Server.Addr(); addr != nil {
+		view.Address = addr.String()
+	}
+
+	writeJSON(w, http.StatusOK, view)
+}
+
+//
+// serveClients writes every connected client.
+//
+func (o *Handler) serveClients(w http.ResponseWriter, r *http.Request) {
+	clients := o.config.Server.Clients()
+	views := make([]clientView, len(clients))
+
+	for i, e := range clients {
+		views[i] = viewOf(e)
+	}
+
+	writeJSON(w, http.StatusOK, views)
+}
+
+//
+// serveClient writes the client with the provided id.
+//
+func (o *Handler) serveClient(w http.ResponseWriter, r *http.Request, rawID string) {
+	client, ok := o.lookup(w, rawID)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, viewOf(client))
+}
+
+//
+// serveKick kicks the client with the provided id.
+//
+func (o *Handler) serveKick(w http.ResponseWriter, r *http.Request, rawID string) {
+	client, ok := o.lookup(w, rawID)
+	if !ok {
+		return
+	}
+
+	reason := r.URL.Query().Get("reason")
+	if len(reason) == 0 {
+		reason = "kicked through the admin API"
+	}
+
+	if _, err := o.config.Server.Kick(client.ID(), reason); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+//
+// serveBroadcast sends the request body as a message to every connected client.
+//
+func (o *Handler) serveBroadcast(w http.ResponseWriter, r *http.Request) {
+	maxSize := o.config.MaxBroadcastSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxBroadcastSize
+	}
+
+	pyld, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxSize)))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+
+		return
+	}
+
+	recipients := o.config.Server.ClientCount()
+
+	err = o.config.Server.SendBytesAll(pyld)
+
+	var broadcastErr *tcp.BroadcastError
+
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, map[string]int{"recipients": recipients, "failures": 0})
+
+	case errors.As(err, &broadcastErr):
+		writeJSON(w, http.StatusOK, map[string]int{
+			"recipients": broadcastErr.Recipients,
+			"failures":   len(broadcastErr.Failures),
+		})
+
+	default:
+		writeError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+//
+// serveShutdown begins a graceful shutdown of the server. It does not wait for the shutdown to
+// complete, as doing so would usually outlive the request.
+//
+func (o *Handler) serveShutdown(w http.ResponseWriter, r *http.Request) {
+	switch state := o.config.Server.State(); state {
+	case tcp.StateStarting, tcp.StateRunning:
+	default:
+		writeError(w, http.StatusConflict, fmt.Sprintf("the server is %s", state))
+
+		return
+	}
+
+	timeout := o.config.ShutdownTimeout
+	if timeout == 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		if err := o.config.Server.Shutdown(ctx); err != nil {
+			o.config.Server.Logger().Warn("A shutdown triggered through the admin API failed.", "error", err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+//
+// lookup finds the client with the provided id, writing an error response and returning false if
+// it cannot be found.
+//
+func (o *Handler) lookup(w http.ResponseWriter, rawID string) (*tcp.Client, bool) {
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "client ids must be integers")
+
+		return nil, false
+	}
+
+	client := o.config.Server.Client(id)
+	if client == nil {
+		writeError(w, http.StatusNotFound, tcp.ErrClientNotFound.Error())
+
+		return nil, false
+	}
+
+	return client, true
+}
+
+//
+// allow runs the provided handler function if the request uses the provided method, and writes an
+// error response otherwise.
+//
+func (o *Handler) allow(w http.ResponseWriter, r *http.Request, method string, fn http.HandlerFunc) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+
+		return
+	}
+
+	fn(w, r)
+}
+
+//
+// viewOf builds the JSON representation of the provided client.
+//
+func viewOf(client *tcp.Client) clientView {
+	stats := client.Stats()
+
+	view := clientView{
+		ID:            client.ID(),
+		Description:   client.String(),
+		RemoteAddr:    client.RemoteAddr(),
+		Groups:        client.Groups(),
+		Subscriptions: client.Subscriptions(),
+		Attributes:    make(map[string]interface{}),
+		Stats: statsView{
+			ConnectedAt:      stats.ConnectedAt,
+			MessagesReceived: stats.MessagesReceived,
+			BytesReceived:    stats.BytesReceived,
+			MessagesSent:     stats.MessagesSent,
+			BytesSent:        stats.BytesSent,
+			SendFailures:     stats.SendFailures,
+			QueueLen:         stats.QueueLen,
+		},
+	}
+
+	if rtt := client.RTT(); rtt > 0 {
+		view.RTT = rtt.String()
+	}
+
+	if !stats.LastRead.IsZero() {
+		view.Stats.LastRead = &stats.LastRead
+	}
+
+	if !stats.LastWrite.IsZero() {
+		view.Stats.LastWrite = &stats.LastWrite
+	}
+
+	//
+	// Attributes may hold anything, so fall back to a printable representation of any that cannot be
+	// encoded as JSON.
+	//
+	for key, value := range client.Attributes() {
+		if _, err := json.Marshal(value); err != nil {
+			view.Attributes[key] = fmt.Sprint(value)
+		} else {
+			view.Attributes[key] = value
+		}
+	}
+
+	return view
+}
+
+//
+// writeJSON writes the provided value as a JSON response with the provided status code.
+//
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(value)
+}
+
+//
+// writeError writes a JSON error response with the provided status code.
+//
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+func TestHandler(t *testing.T) {
+	//
+	// Create and start a new server, and connect a client to it.
+	//
+	chNewClient := make(chan *tcp.Client, 1)
+	chDisconnected := make(chan tcp.DisconnectReason, 1)
+
+	server, err := tcp.CreateServer(&tcp.ServerConfig{
+		Address:              "localhost:0",
+		Delim:                '\n',
+		Logger:               tcp.NopLogger(),
+		OnNewClient:          func(c *tcp.Client) { chNewClient <- c },
+		OnClientDisconnected: func(c *tcp.Client, reason tcp.DisconnectReason) { chDisconnected <- reason },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	client := <-chNewClient
+	client.Set("name", "alice")
+
+	handler, err := CreateHandler(&HandlerConfig{Server: server, ShutdownTimeout: 1 * time.Second})
+	if err != nil {
+		t.Fatalf("The handler failed to create. (Error: %s)", err)
+	}
+
+	//
+	// Assert that the server's state and its client are reported.
+	//
+	var state stateView
+
+	if rec := serve(handler, "GET", "/state", ""); json.NewDecoder(rec.Body).Decode(&state) != nil ||
+		state.State != "running" || state.Clients != 1 {
+		t.Errorf("The server's state was reported incorrectly. (State: %+v)", state)
+	}
+
+	var clients []clientView
+
+	if rec := serve(handler, "GET", "/clients", ""); json.NewDecoder(rec.Body).Decode(&clients) != nil ||
+		len(clients) != 1 || clients[0].ID != client.ID() || clients[0].Attributes["name"] != "alice" {
+		t.Errorf("The connected clients were reported incorrectly. (Clients: %+v)", clients)
+	}
+
+	if rec := serve(handler, "GET", "/clients/12345", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Looking up an unknown client did not fail as expected. (Status: %d)", rec.Code)
+	}
+
+	if rec := serve(handler, "GET", "/broadcast", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Using the wrong method did not fail as expected. (Status: %d)", rec.Code)
+	}
+
+	//
+	// Broadcast a message and assert that the client receives it.
+	//
+	if rec := serve(handler, "POST", "/broadcast", "hello"); rec.Code != http.StatusOK {
+		t.Errorf("The broadcast failed. (Status: %d)", rec.Code)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	if line, _ := bufio.NewReader(conn).ReadString('\n'); line != "hello\n" {
+		t.Errorf("The broadcast message was not received. (Got: %q)", line)
+	}
+
+	//
+	// Kick the client and then shut the server down.
+	//
+	if rec := serve(handler, "POST", "/clients/"+strconv.Itoa(client.ID())+"/kick?reason=test", ""); rec.Code != http.StatusAccepted {
+		t.Errorf("The kick failed. (Status: %d)", rec.Code)
+	}
+
+	if reason := <-chDisconnected; reason != tcp.DisconnectKicked {
+		t.Errorf("The client was disconnected for the wrong reason. (Reason: %s)", reason)
+	}
+
+	if rec := serve(handler, "POST", "/shutdown", ""); rec.Code != http.StatusAccepted {
+		t.Errorf("The shutdown was not accepted. (Status: %d)", rec.Code)
+	}
+
+	deadline := time.Now().Add(1 * time.Second)
+
+	for server.State() != tcp.StateStopped && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if state := server.State(); state != tcp.StateStopped {
+		t.Errorf("The server did not shut down. (State: %s)", state)
+	}
+}
+
+//
+// serve sends a request to the provided handler and returns the recorded response.
+//
+func serve(handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+	return rec
+}
@@ -1,5 +1,9 @@
 package packetsvr
 
+import (
+	"net"
+)
+
 //
 // Server provides a protocol-agnostic interface for packet server implementations.
 //
@@ -9,8 +13,8 @@ type Server interface {
 	// server actually takes to start up. To handle such scenarios, a channel is returned that can be
 	// blocked on. A "true" value will be written to said channel once server start-up is complete.
 	//
-	// It is up to the caller of this method to ensure that subsequent calls are not made prior to
-	// completion of the start-up process. Failure to do so may result in a corrupt program state.
+	// Starting a server that is already starting or running returns an error rather than starting
+	// it twice. A server that has completely stopped may be started again.
 	//
 	Start() (<-chan bool, error)
 
@@ -19,8 +23,16 @@ type Server interface {
 	// server actually takes to shut down. To handle such scenarios, a channel is returned that can be
 	// blocked on. A "true" value will be written to said channel once server shut-down is complete.
 	//
-	// It is up to the caller of this method to ensure that subsequent calls are not made prior to
-	// completion of the shut-down process. Failure to do so may result in a corrupt program state.
+	// Stopping a server that is already stopping or stopped is harmless, and every returned channel
+	// is written to once shut-down is complete. Stopping a server that was never started returns an
+	// error.
 	//
 	Stop() (<-chan bool, error)
+
+	//
+	// Addr returns the network address that the packet server's listener is bound to. This is
+	// particularly useful when the server was configured to bind to port 0, as the port that was
+	// actually chosen is reported. Nil is returned if the server has never been started.
+	//
+	Addr() net.Addr
 }
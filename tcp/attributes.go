@@ -0,0 +1,127 @@
+package tcp
+
+//
+// Set attaches an attribute with the specified key and value to the client, replacing any existing
+// attribute with the same key. Attributes live exactly as long as the client does (and so are still
+// available within the "on client connection closed" handler), which makes them a convenient place
+// to keep per-connection state such as authenticated user ids or negotiated protocol versions.
+//
+func (o *Client) Set(key string, value interface{}) {
+	o.attrsMu.Lock()
+	defer o.attrsMu.Unlock()
+
+	o.attrs[key] = value
+}
+
+//
+// Get returns the value of the client's attribute with the specified key and whether or not such
+// an attribute exists.
+//
+func (o *Client) Get(key string) (interface{}, bool) {
+	o.attrsMu.RLock()
+	defer o.attrsMu.RUnlock()
+
+	value, ok := o.attrs[key]
+
+	return value, ok
+}
+
+//
+// Delete removes the client's attribute with the specified key (if it exists).
+//
+func (o *Client) Delete(key string) {
+	o.attrsMu.Lock()
+	defer o.attrsMu.Unlock()
+
+	delete(o.attrs, key)
+}
+
+//
+// Attributes returns a snapshot of all of the client's attributes.
+//
+func (o *Client) Attributes() map[string]interface{} {
+	o.attrsMu.RLock()
+	defer o.attrsMu.RUnlock()
+
+	attrs := make(map[string]interface{}, len(o.attrs))
+	for key, value := range o.attrs {
+		attrs[key] = value
+	}
+
+	return attrs
+}
+
+//
+// GetString returns the value of the client's attribute with the specified key if it exists and
+// holds a string.
+//
+func (o *Client) GetString(key string) (string, bool) {
+	value, ok := o.Get(key)
+	if !ok {
+		return "", false
+	}
+
+	s, ok := value.(string)
+
+	return s, ok
+}
+
+//
+// GetInt returns the value of the client's attribute with the specified key if it exists and holds
+// an int.
+//
+func (o *Client) GetInt(key string) (int, bool) {
+	value, ok := o.Get(key)
+	if !ok {
+		return 0, false
+	}
+
+	i, ok := value.(int)
+
+	return i, ok
+}
+
+//
+// GetInt64 returns the value of the client's attribute with the specified key if it exists and
+// holds an int64.
+//
+func (o *Client) GetInt64(key string) (int64, bool) {
+	value, ok := o.Get(key)
+	if !ok {
+		return 0, false
+	}
+
+	i, ok := value.(int64)
+
+	return i, ok
+}
+
+//
+// GetFloat64 returns the value of the client's attribute with the specified key if it exists and
+// holds a float64.
+//
+func (o *Client) GetFloat64(key string) (float64, bool) {
+	value, ok := o.Get(key)
+	if !ok {
+		return 0, false
+	}
+
+	f, ok := value.(float64)
+
+	return f, ok
+}
+
+//
+// GetBool returns the value of the client's attribute with the specified key if it exists and
+// holds a bool.
+//
+func (o *Client) GetBool(key string) (bool, bool) {
+	value, ok := o.Get(key)
+	if !ok {
+		return false, false
+	}
+
+	b, ok := value.(bool)
+
+	return b, ok
+}
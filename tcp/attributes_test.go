@@ -0,0 +1,51 @@
+package tcp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestClientAttributes(t *testing.T) {
+	server, err := CreateServer(&ServerConfig{Address: TestServerAddress})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	serverConn, clientConn := net.Pipe()
+
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	client := CreateClientWithFramer(0, serverConn, server, server.framer)
+
+	//
+	// Set a few attributes and assert that the typed helpers only return values of the right type.
+	//
+	client.Set("user", "luke")
+	client.Set("version", 2)
+
+	if user, ok := client.GetString("user"); !ok || user != "luke" {
+		t.Errorf("A string attribute was not returned as expected. (Got: %q)", user)
+	}
+
+	if version, ok := client.GetInt("version"); !ok || version != 2 {
+		t.Errorf("An int attribute was not returned as expected. (Got: %d)", version)
+	}
+
+	if _, ok := client.GetString("version"); ok {
+		t.Error("An int attribute was returned as a string.")
+	}
+
+	if _, ok := client.GetBool("missing"); ok {
+		t.Error("A missing attribute was returned.")
+	}
+
+	//
+	// Delete an attribute and assert that it is gone from the snapshot.
+	//
+	client.Delete("user")
+
+	if attrs := client.Attributes(); len(attrs) != 1 || attrs["version"] != 2 {
+		t.Errorf("The attribute snapshot was not as expected. (Got: %v)", attrs)
+	}
+}
@@ -0,0 +1,111 @@
+package tcp
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+//
+// broadcastBatchSize is the number of clients that a broadcast sends to before it becomes worth
+// fanning the sends out across multiple goroutines.
+//
+const broadcastBatchSize = 128
+
+//
+// SendFailure describes a single client that a broadcast could not be sent to.
+//
+type SendFailure struct {
+	Client *Client // The client that the broadcast could not be sent to.
+	Err    error   // Why the broadcast could not be sent to the client.
+}
+
+//
+// BroadcastError is returned when a broadcast could not be sent to one or more of its recipients.
+//
+type BroadcastError struct {
+	Recipients int           // The total number of clients that the broadcast was sent to.
+	Failures   []SendFailure // Each client that the broadcast could not be sent to, ordered by client id.
+}
+
+//
+// Error implements the method described by the error interface.
+//
+func (o *BroadcastError) Error() string {
+	ids := make([]string, len(o.Failures))
+	for i, failure := range o.Failures {
+		ids[i] = fmt.Sprintf("%d", failure.Client.ID())
+	}
+
+	return fmt.Sprintf(
+		"failed to send to %d of %d clients (ids: %s)",
+		len(o.Failures),
+		o.Recipients,
+		strings.Join(ids, ", "),
+	)
+}
+
+//
+// broadcast sends an already encoded frame to each of the provided clients. If there are enough
+// clients for it to matter, the sends are fanned out across a pool of worker goroutines. A
+// *BroadcastError is returned if the send fails for any of the clients.
+//
+func (o *Server) broadcast(clients []*Client, frame []byte) error {
+	errs := make([]error, len(clients))
+
+	if len(clients) <= broadcastBatchSize {
+		for i, client := range clients {
+			errs[i] = client.sendFrame(frame)
+		}
+	} else {
+		workers := (len(clients) + broadcastBatchSize - 1) / broadcastBatchSize
+		if workers > runtime.GOMAXPROCS(0) {
+			workers = runtime.GOMAXPROCS(0)
+		}
+
+		chIndexes := make(chan int, len(clients))
+		for i := range clients {
+			chIndexes <- i
+		}
+		close(chIndexes)
+
+		wg := &sync.WaitGroup{}
+		wg.Add(workers)
+
+		for w := 0; w < workers; w++ {
+			go func() {
+				defer wg.Done()
+
+				for i := range chIndexes {
+					errs[i] = clients[i].sendFrame(frame)
+				}
+			}()
+		}
+
+		wg.Wait()
+	}
+
+	//
+	// Gather up any failures.
+	//
+	var failures []SendFailure
+
+	for i, err := range errs {
+		if err != nil {
+			o.logger.Warn(
+				"Failed to send a broadcast message to the TCP/IP client. The client may have already "+
+					"disconnected.",
+				clients[i].logFields("error", err)...,
+			)
+
+			failures = append(failures, SendFailure{Client: clients[i], Err: err})
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return &BroadcastError{Recipients: len(clients), Failures: failures}
+}
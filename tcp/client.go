@@ -2,35 +2,120 @@ package tcp
 
 import (
 	"bufio"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// DefaultSendQueueSize is the number of outbound messages that may be queued for a single client
+// if no other queue size has been configured.
+//
+const DefaultSendQueueSize = 256
+
+//
+// closeFlushTimeout is how long a deliberately closed client's remaining outbound messages are given
+// to be written if no write timeout has been configured.
+//
+const closeFlushTimeout = 5 * time.Second
+
+//
+// ErrSendQueueFull is returned when a message could not be queued for a client because its
+// outbound queue is full.
+//
+var ErrSendQueueFull = errors.New("the client's outbound queue is full")
+
+//
+// ErrClientClosed is returned when attempting to send to a client that has been closed.
+//
+var ErrClientClosed = errors.New("the client has been closed")
+
+//
+// SlowConsumerPolicy describes what a server should do when a message is sent to a client whose
+// outbound queue is full (i.e. the client is not reading as fast as messages are being sent).
+//
+type SlowConsumerPolicy int
+
+const (
+	SlowConsumerBlock      SlowConsumerPolicy = iota // Block the sender until there is room in the queue. This is the default.
+	SlowConsumerDropNewest                           // Drop the message being sent.
+	SlowConsumerDropOldest                           // Drop the oldest queued message to make room for the message being sent.
+	SlowConsumerDisconnect                           // Disconnect the client.
 )
 
 //
 // Client holds info about a single client connection.
 //
 type Client struct {
-	id     int       // The unique id assigned to the client.
-	conn   net.Conn  // Literal connection to the client.
-	server *Server   // The server that the client belongs to.
-	delim  byte      // The byte that should act as a message delimiter.
-	chStop chan bool // Channel that will be used to tell the client's handler loop to stop.
-	chDone chan bool // Channel that will be used to tell whoever cares that the client's handler loop has stopped.
+	id            int                    // The unique id assigned to the client.
+	conn          net.Conn               // Literal connection to the client.
+	server        *Server                // The server that the client belongs to.
+	framer        Framer                 // The framer used to split inbound data into messages and encode outbound ones.
+	chSend        chan []byte            // Channel acting as the client's queue of encoded outbound messages.
+	chStop        chan bool              // Channel that is closed to tell the client's handler loop to stop.
+	chDone        chan bool              // Channel that is closed to tell whoever cares that the client's handler loop has stopped.
+	closeOnce     *sync.Once             // Ensures that the client's stop channel is only closed once.
+	chDrain       chan bool              // Channel that is closed to tell the client to flush its outbound queue and disconnect.
+	drainOnce     *sync.Once             // Ensures that the client's drain channel is only closed once.
+	chFlush       chan bool              // Channel used to ask the writer to flush the outbound queue.
+	chFlushed     chan bool              // Channel used by the writer to tell whoever cares that the outbound queue has been flushed.
+	mu            *sync.Mutex            // Synchronizes access to the client's disconnect reason, heartbeat state, and statistics.
+	reason        DisconnectReason       // Why the client was disconnected, or DisconnectNone if it has not been.
+	reasonErr     error                  // The error (if any) underlying the client's disconnect reason.
+	lastSeen      time.Time              // When a message was last received from the client.
+	pingSentAt    time.Time              // When the outstanding heartbeat ping was sent, or zero if none is outstanding.
+	rtt           time.Duration          // The most recently measured heartbeat round-trip time.
+	stats         ClientStats            // The client's traffic statistics.
+	groups        map[string]bool        // The names of the groups that the client is a member of. Guarded by the server's lock.
+	subscriptions map[string]bool        // The topic patterns that the client is subscribed to. Guarded by the server's lock.
+	attrsMu       *sync.RWMutex          // Synchronizes access to the client's attributes.
+	attrs         map[string]interface{} // Arbitrary attributes that handlers have attached to the client.
 }
 
 //
-// CreateClient instantiates and returns a new client instance.
+// CreateClient instantiates and returns a new client instance whose messages are split on the
+// provided delimiter.
 //
 func CreateClient(id int, conn net.Conn, server *Server, delim byte) *Client {
+	return CreateClientWithFramer(id, conn, server, &DelimFramer{Delim: delim})
+}
+
+//
+// CreateClientWithFramer instantiates and returns a new client instance whose messages are split
+// and encoded by the provided framer.
+//
+func CreateClientWithFramer(id int, conn net.Conn, server *Server, framer Framer) *Client {
+	queueSize := server.config.SendQueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultSendQueueSize
+	}
+
+	now := time.Now()
+
 	o := &Client{
-		id:     id,
-		conn:   conn,
-		server: server,
-		delim:  delim,
-		chStop: make(chan bool, 1),
-		chDone: make(chan bool, 1),
+		id:            id,
+		conn:          conn,
+		server:        server,
+		framer:        framer,
+		chSend:        make(chan []byte, queueSize),
+		chStop:        make(chan bool),
+		chDone:        make(chan bool),
+		chDrain:       make(chan bool),
+		drainOnce:     &sync.Once{},
+		chFlush:       make(chan bool),
+		chFlushed:     make(chan bool, 1),
+		closeOnce:     &sync.Once{},
+		mu:            &sync.Mutex{},
+		lastSeen:      now,
+		stats:         ClientStats{ConnectedAt: now},
+		groups:        make(map[string]bool),
+		subscriptions: make(map[string]bool),
+		attrsMu:       &sync.RWMutex{},
+		attrs:         make(map[string]interface{}),
 	}
 
 	return o
@@ -40,7 +125,7 @@ func CreateClient(id int, conn net.Conn, server *Server, delim byte) *Client {
 // String returns a printable representation of the client.
 //
 func (o *Client) String() string {
-	return fmt.Sprintf("%05d TCP %21s", o.ID(), o.RemoteAddr())
+	return fmt.Sprintf("%05d %s %21s", o.ID(), strings.ToUpper(o.conn.LocalAddr().Network()), o.RemoteAddr())
 }
 
 //
@@ -90,12 +175,32 @@ func (o *Client) LocalAddr() string {
 //
 // Close beigns the process of closing the current connection to the client. It returns a channel
 // that can optionally be blocked on if the caller would like to know when the connection has been
-// completely closed.
+// completely closed. It is safe to call more than once.
 //
 func (o *Client) Close() <-chan bool {
-	o.chStop <- true
+	return o.closeWithReason(DisconnectClosed, nil)
+}
 
-	return o.chDone
+//
+// DisconnectReason returns why the client was disconnected, or DisconnectNone if it is still
+// connected.
+//
+func (o *Client) DisconnectReason() DisconnectReason {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.reason
+}
+
+//
+// DisconnectErr returns the error (if any) underlying the client's disconnect reason, such as the
+// error that a failed read returned or the reason that the client was kicked.
+//
+func (o *Client) DisconnectErr() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.reasonErr
 }
 
 //
@@ -106,16 +211,105 @@ func (o *Client) Send(message string) error {
 }
 
 //
-// SendBytes appends the appropriate delimiter and then sends the specified bytes to the client.
+// SendBytes encodes the specified bytes as a frame using the client's framer and then queues them
+// to be sent to the client by its writer goroutine. If the client's outbound queue is full, the
+// server's slow consumer policy decides what happens.
+//
+// NOTE: Because the actual write happens asynchronously, a nil error only means that the message
+//  was queued. Write failures cause the client to be closed.
 //
 func (o *Client) SendBytes(b []byte) error {
-	b = append(b, o.delim)
+	frame, err := o.framer.EncodeFrame(b)
+	if err != nil {
+		return err
+	}
+
+	return o.sendFrame(frame)
+}
 
-	_, err := o.conn.Write(b)
+//
+// sendFrame queues an already encoded frame to be sent to the client, reporting a send failure if
+// it could not be queued.
+//
+func (o *Client) sendFrame(frame []byte) error {
+	err := o.queueFrame(frame)
+	if err != nil {
+		o.recordSendFailure()
+	}
 
 	return err
 }
 
+//
+// queueFrame queues an already encoded frame to be sent to the client, applying the server's slow
+// consumer policy if the client's outbound queue is full.
+//
+func (o *Client) queueFrame(frame []byte) error {
+	policy := o.server.config.SlowConsumerPolicy
+
+	//
+	// Attempt to queue the frame, only blocking if that is what the policy calls for.
+	//
+	if policy == SlowConsumerBlock {
+		select {
+		case o.chSend <- frame:
+			return nil
+
+		case <-o.chStop:
+			return ErrClientClosed
+		}
+	}
+
+	select {
+	case o.chSend <- frame:
+		return nil
+
+	case <-o.chStop:
+		return ErrClientClosed
+
+	default:
+	}
+
+	//
+	// The queue is full, so apply the policy.
+	//
+	o.server.logger.Warn("The TCP/IP client's outbound queue is full.", o.logFields()...)
+
+	o.server.onSlowConsumer(o)
+
+	switch policy {
+	case SlowConsumerDropOldest:
+		for {
+			select {
+			case <-o.chSend:
+			default:
+			}
+
+			select {
+			case o.chSend <- frame:
+				return nil
+
+			case <-o.chStop:
+				return ErrClientClosed
+
+			default:
+			}
+		}
+
+	case SlowConsumerDisconnect:
+		o.closeWithReason(DisconnectSlowConsumer, ErrSendQueueFull)
+	}
+
+	return ErrSendQueueFull
+}
+
+//
+// QueueLen returns the number of outbound messages currently queued for the client.
+//
+func (o *Client) QueueLen() int {
+	return len(o.chSend)
+}
+
 //
 // logPrefix actually generates the prefix strings returned by the varous "*LogPrefix()" methods
 // that are provided with public visibility.
@@ -124,44 +318,333 @@ func (o *Client) logPrefix(symbol string) string {
 	return fmt.Sprintf("<~> %s %s ", o.String(), symbol)
 }
 
+//
+// logFields returns the key/value pairs that identify the client in log events, followed by the
+// provided ones.
+//
+func (o *Client) logFields(keyvals ...interface{}) []interface{} {
+	fields := []interface{}{
+		"client_id", o.id,
+		"network", o.conn.LocalAddr().Network(),
+		"remote_addr", o.RemoteAddr(),
+	}
+
+	return append(fields, keyvals...)
+}
+
+//
+// closeWithReason records the provided disconnect reason (unless one has already been recorded) and
+// then begins the process of closing the client.
+//
+func (o *Client) closeWithReason(reason DisconnectReason, err error) <-chan bool {
+	o.setDisconnectReason(reason, err)
+	o.stop()
+
+	return o.chDone
+}
+
+//
+// setDisconnectReason records the provided disconnect reason unless one has already been recorded.
+// The first reason recorded wins, as any that follow are usually fallout from the first (e.g. a read
+// failing because the connection was closed after a kick). Returns whether or not the provided
+// reason was recorded.
+//
+func (o *Client) setDisconnectReason(reason DisconnectReason, err error) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.reason != DisconnectNone {
+		return false
+	}
+
+	o.reason = reason
+	o.reasonErr = err
+
+	return true
+}
+
+//
+// stop closes the client's stop channel (if it has not been already), which tells its handler loop,
+// its writer goroutine, and any blocked senders to give up.
+//
+func (o *Client) stop() {
+	o.closeOnce.Do(func() {
+		close(o.chStop)
+	})
+}
+
+//
+// write drains the client's outbound queue, writing each message to the connection, until the
+// client is stopped. If a write fails, the client is closed.
+//
+func (o *Client) write(chWriterDone chan<- bool) {
+	defer close(chWriterDone)
+
+	for {
+		select {
+		case frame := <-o.chSend:
+			if !o.writeFrame(frame) {
+				return
+			}
+
+		case <-o.chFlush:
+			//
+			// Write everything that is currently queued before acknowledging the flush, so that the
+			// flusher knows that whatever it queued beforehand has made it out. Anything queued after
+			// the flush was asked for is left for later so that a steady stream of new messages cannot
+			// hold it up forever.
+			//
+			// NOTE: The slow consumer policy may drop queued messages out from under us, in which case
+			//  the queue runs dry early.
+			//
+			for n := len(o.chSend); n > 0; n-- {
+				frame, ok := o.tryReceiveFrame()
+				if !ok {
+					break
+				}
+
+				if !o.writeFrame(frame) {
+					return
+				}
+			}
+
+			o.chFlushed <- true
+
+		case <-o.chStop:
+			return
+		}
+	}
+}
+
+//
+// tryReceiveFrame takes the oldest frame off of the client's outbound queue without blocking.
+// Returns false if the queue is empty.
+//
+func (o *Client) tryReceiveFrame() ([]byte, bool) {
+	select {
+	case frame := <-o.chSend:
+		return frame, true
+
+	default:
+		return nil, false
+	}
+}
+
+//
+// writeFrame writes the provided frame to the connection on behalf of the writer goroutine. If the
+// write fails, the client is closed and false is returned.
+//
+func (o *Client) writeFrame(frame []byte) bool {
+	if timeout := o.server.config.WriteTimeout; timeout > 0 {
+		o.conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+
+	if _, err := o.conn.Write(frame); err != nil {
+		o.recordSendFailure()
+
+		reason := DisconnectWriteError
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			reason = DisconnectWriteTimeout
+		}
+
+		o.server.logger.Warn(
+			"Write to the TCP/IP client failed. The client may have already disconnected, or may "+
+				"have stopped reading.",
+			o.logFields("error", err)...,
+		)
+
+		if o.setDisconnectReason(reason, err) {
+			o.server.onError(o, &OpError{Op: "write", Err: err})
+		}
+
+		o.stop()
+
+		return false
+	}
+
+	o.recordSent(len(frame))
+
+	return true
+}
+
+//
+// flushQueue waits for the client's writer goroutine to stop and then writes whatever is left in
+// the client's outbound queue, giving up once the configured write timeout (or closeFlushTimeout if
+// there is none) passes.
+//
+func (o *Client) flushQueue(chWriterDone <-chan bool) {
+	timeout := o.server.config.WriteTimeout
+	if timeout <= 0 {
+		timeout = closeFlushTimeout
+	}
+
+	//
+	// NOTE: Setting the deadline up front also bounds any write that the writer goroutine is in the
+	//  middle of, which we must wait on so that the two of us do not interleave frames.
+	//
+	o.conn.SetWriteDeadline(time.Now().Add(timeout))
+
+	<-chWriterDone
+
+	for {
+		select {
+		case frame := <-o.chSend:
+			if _, err := o.conn.Write(frame); err != nil {
+				o.server.logger.Debug(
+					"Failed to flush the TCP/IP client's outbound queue before closing it.",
+					o.logFields("error", err)...,
+				)
+
+				return
+			}
+
+			o.recordSent(len(frame))
+
+		default:
+			return
+		}
+	}
+}
+
+//
+// handshake completes the TLS handshake of the client's connection within the configured handshake
+// timeout. It does nothing for plain connections or if no handshake timeout has been configured, in
+// which case any handshake happens implicitly upon the first read or write.
+//
+func (o *Client) handshake() error {
+	tlsConn, ok := o.conn.(*tls.Conn)
+	if !ok || o.server.config.HandshakeTimeout <= 0 {
+		return nil
+	}
+
+	tlsConn.SetDeadline(time.Now().Add(o.server.config.HandshakeTimeout))
+	defer tlsConn.SetDeadline(time.Time{})
+
+	return tlsConn.Handshake()
+}
+
 //
 // listen reads and processes new messages from the client while it is connected. It is intended to
 // be run in its own goroutine per connected client.
 //
 func (o *Client) listen() {
+	//
+	// Complete the TLS handshake (if any) up front so that a client that never finishes it cannot
+	// tie up the connection. Clients that fail the handshake are dropped without ever being reported
+	// to the registered event handlers.
+	//
+	if err := o.handshake(); err != nil {
+		o.server.logger.Warn("The TCP/IP client failed to complete its handshake.", o.logFields("error", err)...)
+
+		o.closeWithReason(DisconnectHandshakeFailed, err)
+
+		o.server.metrics.ClientDisconnected(o.DisconnectReason())
+
+		o.server.forgetClient(o)
+		o.conn.Close()
+
+		close(o.chDone)
+
+		return
+	}
+
+	//
+	// Fire up the writer goroutine that will drain the client's outbound queue.
+	//
+	chWriterDone := make(chan bool)
+
+	go o.write(chWriterDone)
+
+	//
+	// Fire up the heartbeat goroutine that will make sure that the client is still responsive.
+	//
+	chHeartbeatDone := make(chan bool, 1)
+
+	if o.server.config.HeartbeatInterval > 0 {
+		go o.heartbeat(chHeartbeatDone)
+	} else {
+		chHeartbeatDone <- true
+	}
+
 	//
 	// Execute the registered "new client" event handler.
 	//
 	o.server.onNewClient(o)
 
 	//
-	// Create a buffer reader to read recieved messages from the client and begin doing so in a new
-	// goroutine.
+	// Create a buffer reader to read recieved messages from the client and begin splitting them into
+	// frames in a new goroutine.
 	//
 	reader := bufio.NewReader(o.conn)
-	chReader := make(chan string)
+	chReader := make(chan []byte)
+	chOversized := make(chan bool)
+	chReaderQuit := make(chan bool)
 	chReaderDone := make(chan bool, 1)
 
 	go func() {
 		for {
-			msg, err := reader.ReadString(o.delim)
+			if timeout := o.server.config.ReadIdleTimeout; timeout > 0 {
+				o.conn.SetReadDeadline(time.Now().Add(timeout))
+			}
+
+			frame, err := o.framer.ReadFrame(reader, o.server.config.MaxMessageSize)
+
+			if err == ErrMessageTooLarge && o.server.config.OversizePolicy != OversizeDisconnect {
+				o.server.logger.Warn(
+					"Discarding a message from the TCP/IP client that exceeds the maximum message size.",
+					o.logFields("max_message_size", o.server.config.MaxMessageSize)...,
+				)
+
+				if o.server.config.OversizePolicy == OversizeCallback {
+					select {
+					case chOversized <- true:
+					case <-chReaderQuit:
+					}
+				}
+
+				err = o.framer.SkipFrame(reader)
+				if err == nil {
+					continue
+				}
+			}
 
 			if err != nil {
-				if err == io.EOF {
-					log.Printf("%sThe TCP/IP client has disconnected.", o.LogPrefix())
+				reason := readErrorReason(err)
+
+				recorded := o.setDisconnectReason(reason, err)
+
+				if recorded && (reason == DisconnectReadError || reason == DisconnectProtocolViolation) {
+					o.server.onError(o, &OpError{Op: "read", Err: err})
+				}
+
+				if reason == DisconnectPeerClosed {
+					o.server.logger.Info("The TCP/IP client has disconnected.", o.logFields()...)
+				} else if reason == DisconnectOversizedMessage {
+					o.server.logger.Warn(
+						"Disconnecting the TCP/IP client because it sent a message that exceeds the maximum "+
+							"message size.",
+						o.logFields("max_message_size", o.server.config.MaxMessageSize)...,
+					)
+				} else if reason == DisconnectIdleTimeout {
+					o.server.logger.Warn(
+						"Disconnecting the TCP/IP client because it did not send a complete message in time.",
+						o.logFields("timeout", o.server.config.ReadIdleTimeout)...,
+					)
 				} else {
-					log.Printf(
-						"%sBuffer read for the TCP/IP client failed. (Error: %s) (Hint: Did the server "+
-							"shut down with clients still connected?)",
-						o.LogPrefix(),
-						err,
+					o.server.logger.Debug(
+						"Buffer read for the TCP/IP client failed. Did the server shut down with clients still "+
+							"connected?",
+						o.logFields("error", err)...,
 					)
 				}
 
 				break
 			}
 
-			chReader <- msg
+			select {
+			case chReader <- frame:
+			case <-chReaderQuit:
+			}
 		}
 
 		close(chReader)
@@ -170,19 +653,32 @@ func (o *Client) listen() {
 	}()
 
 	//
-	// Select on either new messages or a kill signal.
+	// Select on either new messages, notice of an oversized message, or a kill signal.
 	//
 	stop := false
 
 	for !stop {
 		select {
-		case msg, ok := <-chReader:
+		case frame, ok := <-chReader:
 			if !ok {
 				stop = true
 			} else {
-				o.server.onNewMessage(o, msg)
+				o.recordReceived(frameSize(o.framer, frame))
+
+				if !o.handleHeartbeat(frame) {
+					o.server.onNewMessage(o, frame)
+				}
 			}
 
+		case <-chOversized:
+			o.server.onOversizedMessage(o)
+
+		case <-o.chDrain:
+			o.flush()
+			o.setDisconnectReason(DisconnectServerShutdown, nil)
+
+			stop = true
+
 		case <-o.chStop:
 			stop = true
 		}
@@ -191,19 +687,42 @@ func (o *Client) listen() {
 	//
 	// Shutdown the connection.
 	//
+	// NOTE: The reader goroutine may be blocked trying to hand us a message that we will never
+	//  receive, so we tell it to stop trying before we wait for it below.
+	//
+	close(chReaderQuit)
+
+	o.setDisconnectReason(DisconnectClosed, nil)
+	o.stop()
+
+	o.server.logger.Info("The TCP/IP client's connection is closing.", o.logFields("reason", o.DisconnectReason())...)
+
 	o.server.onClientConnectionClosed(o)
+	o.server.onClientDisconnected(o, o.DisconnectReason())
+	o.server.metrics.ClientDisconnected(o.DisconnectReason())
 	o.server.forgetClient(o)
+
+	//
+	// If the client was deliberately closed or kicked, give whatever was queued for it beforehand
+	// (e.g. a final message sent right before the call to Close) a chance to be written.
+	//
+	if reason := o.DisconnectReason(); reason == DisconnectClosed || reason == DisconnectKicked {
+		o.flushQueue(chWriterDone)
+	}
+
 	o.conn.Close()
 
 	//
-	// Block until the reader goroutine completes.
+	// Block until the reader, writer, and heartbeat goroutines complete.
 	//
 	<-chReaderDone
+	<-chWriterDone
+	<-chHeartbeatDone
 
 	//
 	// Tell anyone waiting on us that we are done.
 	//
-	o.chDone <- true
+	close(o.chDone)
 
 	return
 }
@@ -0,0 +1,439 @@
+package tcp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+//
+// Default values for the optional attributes of a connector configuration.
+//
+const (
+	DefaultMinBackoff  = 100 * time.Millisecond
+	DefaultMaxBackoff  = 30 * time.Second
+	DefaultDialTimeout = 10 * time.Second
+)
+
+//
+// ErrNotConnected is returned when attempting to send to a connector that does not currently have
+// a live connection.
+//
+var ErrNotConnected = errors.New("the connector is not currently connected")
+
+//
+// ConnectorConfig holds various configuration attributes for creating a new connector.
+//
+type ConnectorConfig struct {
+	Address        string                                  // The "{address}:{port}" to dial.
+	OnConnect      func(connector *Connector)              // Handler function to execute each time a connection is established.
+	OnDisconnect   func(connector *Connector)              // Handler function to execute each time an established connection is lost. Do not expect connection to still be alive when executed.
+	OnMessage      func(connector *Connector, msg string)  // Handler function to execute when a new message is recieved. The message is exactly as read by the framer (e.g. with its trailing delimiter).
+	OnMessageBytes func(connector *Connector, pyld []byte) // Handler function to execute with the raw payload of a new message, stripped of any framing bytes. The handler may retain the slice.
+	Delim          byte                                    // The delimiter that should be expected when splitting packets up into messages. Ignored if a framer is provided.
+	Framer         Framer                                  // The framer used to split packets up into messages. Defaults to a DelimFramer using Delim.
+	MaxMessageSize int                                     // The maximum size in bytes of a single inbound message's payload (not counting framing bytes). Larger messages are discarded. Zero means unlimited.
+	TLSConfig      *tls.Config                             // Secure connection configuration attributes. Nil dials plain TCP/IP connections.
+	DialTimeout    time.Duration                           // How long a single connection attempt may take. Defaults to DefaultDialTimeout.
+	MinBackoff     time.Duration                           // The delay before the first reconnection attempt. Defaults to DefaultMinBackoff.
+	MaxBackoff     time.Duration                           // The maximum delay between reconnection attempts. Defaults to DefaultMaxBackoff.
+	Logger         Logger                                  // The logger that lifecycle events are reported to. Defaults to DefaultLogger.
+}
+
+//
+// Connector maintains an outbound connection to a packet server, speaking the same framing as the
+// server side and automatically reconnecting (with exponential backoff and jitter) whenever the
+// connection drops.
+//
+type Connector struct {
+	mu        *sync.Mutex      // Synchronizes access to the current connection and lifecycle channels.
+	config    *ConnectorConfig // Basic configuration attributes of the connector.
+	framer    Framer           // Framer used to split inbound streams into messages and to encode outbound ones.
+	logger    Logger           // The logger that lifecycle events are reported to.
+	conn      net.Conn         // The current connection, or nil if not currently connected.
+	started   bool             // Whether or not the connector has been started.
+	chStarted chan bool        // Channel that will be used to tell whoever cares that the first connection has been established.
+	chStop    chan bool        // Channel that is closed to tell the connector's lifecycle loop to stop.
+	chStopped chan bool        // Channel that will be used to tell whoever cares that the connector's lifecycle loop has stopped.
+	stopOnce  *sync.Once       // Ensures that the connector is only stopped once.
+}
+
+//
+// CreateConnector creates a new connector instance. It does not connect until started.
+//
+func CreateConnector(config *ConnectorConfig) (*Connector, error) {
+	LoggerFor(config.Logger).Info("Creating a TCP/IP packet connector.", "address", config.Address)
+
+	if len(config.Address) == 0 {
+		return nil, errors.New("an address ({ip}:{port}) must be specified")
+	}
+
+	if framer, ok := config.Framer.(*LengthPrefixFramer); ok {
+		if err := framer.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	framer := config.Framer
+	if framer == nil {
+		framer = &DelimFramer{Delim: config.Delim}
+	}
+
+	connector := &Connector{
+		mu:     &sync.Mutex{},
+		config: config,
+		framer: framer,
+		logger: LoggerFor(config.Logger),
+	}
+
+	return connector, nil
+}
+
+//
+// Dial creates a new connector and makes a single, synchronous attempt to connect it. If that
+// attempt fails, its error is returned. Otherwise, the connector is returned already connected and
+// will automatically reconnect from then on.
+//
+func Dial(config *ConnectorConfig) (*Connector, error) {
+	connector, err := CreateConnector(config)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := connector.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	chStarted, err := connector.start(conn)
+	if err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	<-chStarted
+
+	return connector, nil
+}
+
+//
+// Start begins connecting in the background. A "true" value will be written to the returned
+// channel once the first connection has been established. Failed attempts are retried until the
+// connector is stopped. A connector may only be started once.
+//
+func (o *Connector) Start() (<-chan bool, error) {
+	o.logger.Info("Attempting to start the TCP/IP packet connector...")
+
+	return o.start(nil)
+}
+
+//
+// Stop closes the current connection (if any) and stops reconnecting. A "true" value will be
+// written to the returned channel once the connector has completely stopped.
+//
+func (o *Connector) Stop() (<-chan bool, error) {
+	o.logger.Info("Attempting to stop the TCP/IP packet connector...")
+
+	o.mu.Lock()
+	started, stopOnce, chStop, chStopped := o.started, o.stopOnce, o.chStop, o.chStopped
+	o.mu.Unlock()
+
+	if !started {
+		return nil, errors.New("the connector has not been started")
+	}
+
+	stopOnce.Do(func() {
+		close(chStop)
+
+		o.mu.Lock()
+		if o.conn != nil {
+			o.conn.Close()
+		}
+		o.mu.Unlock()
+	})
+
+	return chStopped, nil
+}
+
+//
+// Connected returns whether or not the connector currently has a live connection.
+//
+func (o *Connector) Connected() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.conn != nil
+}
+
+//
+// Send sends the specified message over the current connection.
+//
+func (o *Connector) Send(msg string) error {
+	return o.SendBytes([]byte(msg))
+}
+
+//
+// SendBytes encodes the specified bytes as a frame and sends them over the current connection. If
+// the connector is not currently connected, ErrNotConnected is returned.
+//
+func (o *Connector) SendBytes(b []byte) error {
+	frame, err := o.framer.EncodeFrame(b)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	conn := o.conn
+	o.mu.Unlock()
+
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	_, err = conn.Write(frame)
+
+	return err
+}
+
+//
+// start initializes the connector's channels and fires up its lifecycle loop, optionally with a
+// connection that has already been established. An error is returned if the connector has already
+// been started.
+//
+func (o *Connector) start(conn net.Conn) (<-chan bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.started {
+		return nil, errors.New("the connector has already been started")
+	}
+
+	o.started = true
+	o.chStarted = make(chan bool, 1)
+	o.chStop = make(chan bool)
+	o.chStopped = make(chan bool, 1)
+	o.stopOnce = &sync.Once{}
+
+	go o.run(conn)
+
+	return o.chStarted, nil
+}
+
+//
+// run handles the entire lifecycle of the connector once started, connecting, reading until the
+// connection drops, and then reconnecting after a backoff delay until stopped. The backoff only
+// starts over once a connection has proven itself healthy (by carrying a message or by staying up
+// for at least the maximum backoff), so that a server which accepts and immediately drops
+// connections is not redialed in a tight loop.
+//
+func (o *Connector) run(conn net.Conn) {
+	started := false
+	attempt := 0
+
+	for {
+		//
+		// If we do not have a connection, attempt to establish one, backing off and trying again if
+		// the attempt fails.
+		//
+		if conn == nil {
+			var err error
+
+			conn, err = o.dial()
+			if err != nil {
+				delay := o.backoff(attempt)
+				attempt++
+
+				o.logger.Warn(
+					"Failed to connect the TCP/IP packet connector. Will retry after a delay.",
+					"address", o.config.Address,
+					"delay", delay,
+					"error", err,
+				)
+
+				select {
+				case <-time.After(delay):
+					continue
+
+				case <-o.chStop:
+				}
+
+				break
+			}
+		}
+
+		//
+		// Publish the connection, unless we were stopped while it was being established.
+		//
+		o.mu.Lock()
+		select {
+		case <-o.chStop:
+			o.mu.Unlock()
+			conn.Close()
+
+			o.chStopped <- true
+
+			return
+
+		default:
+			o.conn = conn
+		}
+		o.mu.Unlock()
+
+		o.logger.Info("The TCP/IP packet connector has connected.", "remote_addr", conn.RemoteAddr())
+
+		if !started {
+			started = true
+			o.chStarted <- true
+		}
+
+		if o.config.OnConnect != nil {
+			o.config.OnConnect(o)
+		}
+
+		//
+		// Read until the connection drops (or is closed because we were stopped), and then clean it
+		// up.
+		//
+		connectedAt := time.Now()
+		healthy := o.read(conn) || time.Since(connectedAt) >= o.maxBackoff()
+
+		o.mu.Lock()
+		o.conn = nil
+		o.mu.Unlock()
+
+		conn.Close()
+		conn = nil
+
+		if o.config.OnDisconnect != nil {
+			o.config.OnDisconnect(o)
+		}
+
+		if healthy {
+			attempt = 0
+		}
+
+		delay := o.backoff(attempt)
+		attempt++
+
+		select {
+		case <-time.After(delay):
+			continue
+
+		case <-o.chStop:
+		}
+
+		break
+	}
+
+	o.logger.Info("The TCP/IP packet connector has been stopped.")
+
+	o.chStopped <- true
+}
+
+//
+// read reads and processes messages from the provided connection until it fails. It returns
+// whether or not at least one message was received.
+//
+func (o *Connector) read(conn net.Conn) bool {
+	reader := bufio.NewReader(conn)
+	received := false
+
+	for {
+		frame, err := o.framer.ReadFrame(reader, o.config.MaxMessageSize)
+		if err == nil || err == ErrMessageTooLarge {
+			received = true
+		}
+
+		if err == ErrMessageTooLarge {
+			o.logger.Warn(
+				"Discarding a message that exceeds the maximum message size.",
+				"max_message_size", o.config.MaxMessageSize,
+			)
+
+			err = o.framer.SkipFrame(reader)
+			if err == nil {
+				continue
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				o.logger.Info("The TCP/IP packet connector's connection was closed by the server.")
+			} else {
+				o.logger.Debug(
+					"Buffer read for the TCP/IP packet connector failed. Was the connector stopped?",
+					"error", err,
+				)
+			}
+
+			return received
+		}
+
+		if o.config.OnMessage != nil {
+			o.config.OnMessage(o, string(frame))
+		}
+
+		if o.config.OnMessageBytes != nil {
+			o.config.OnMessageBytes(o, o.framer.Payload(frame))
+		}
+	}
+}
+
+//
+// dial makes a single attempt to establish a new connection.
+//
+func (o *Connector) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: o.config.DialTimeout}
+	if dialer.Timeout <= 0 {
+		dialer.Timeout = DefaultDialTimeout
+	}
+
+	if o.config.TLSConfig != nil {
+		return tls.DialWithDialer(dialer, "tcp", o.config.Address, o.config.TLSConfig)
+	}
+
+	return dialer.Dial("tcp", o.config.Address)
+}
+
+//
+// backoff returns how long to wait before making the provided (zero-based) reconnection attempt.
+// The delay grows exponentially from the minimum backoff up to the maximum backoff, and is then
+// jittered down by up to half so that many connectors that lost their connections at the same time
+// do not all reconnect in lockstep.
+//
+func (o *Connector) backoff(attempt int) time.Duration {
+	min := o.config.MinBackoff
+	if min <= 0 {
+		min = DefaultMinBackoff
+	}
+
+	max := o.maxBackoff()
+
+	delay := min
+	for i := 0; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		delay = max
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+//
+// maxBackoff returns the maximum delay between reconnection attempts.
+//
+func (o *Connector) maxBackoff() time.Duration {
+	if o.config.MaxBackoff <= 0 {
+		return DefaultMaxBackoff
+	}
+
+	return o.config.MaxBackoff
+}
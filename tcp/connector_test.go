@@ -0,0 +1,164 @@
+package tcp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnectorReconnects(t *testing.T) {
+	//
+	// Create a new server that echoes every message it receives.
+	//
+	config := &ServerConfig{
+		Address:      "127.0.0.1:0",
+		Delim:        '\x00',
+		OnNewMessage: func(c *Client, message string) { c.Send(message[:len(message)-1]) },
+	}
+
+	server, err := CreateServer(config)
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Pin the port that was chosen so that the server comes back on the same one when it is
+	// restarted below.
+	//
+	config.Address = server.Addr().String()
+
+	//
+	// Dial the server with a connector that reports back what it sees.
+	//
+	chConnect := make(chan bool, 2)
+	chMessage := make(chan string, 2)
+
+	connector, err := Dial(&ConnectorConfig{
+		Address:    config.Address,
+		Delim:      '\x00',
+		OnConnect:  func(c *Connector) { chConnect <- true },
+		OnMessage:  func(c *Connector, message string) { chMessage <- message },
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("The connector failed to dial. (Error: %s)", err)
+	}
+
+	<-chConnect
+
+	if _, err := connector.Start(); err == nil {
+		t.Error("A connector that was already started by dialing was started again.")
+	}
+
+	//
+	// Assert that messages make the round trip.
+	//
+	if err := connector.Send("hello"); err != nil {
+		t.Fatalf("The connector failed to send. (Error: %s)", err)
+	}
+
+	select {
+	case message := <-chMessage:
+		if message != "hello\x00" {
+			t.Errorf("A message was recieved, but it was not equal to what was expected. (Got: %q)", message)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("The \"OnMessage\" event handler never fired.")
+	}
+
+	//
+	// Restart the server and assert that the connector reconnects on its own.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+
+	chStarted, _ = server.Start()
+
+	<-chStarted
+
+	select {
+	case <-chConnect:
+	case <-time.After(1 * time.Second):
+		t.Fatal("The connector never reconnected after the server restarted.")
+	}
+
+	if !connector.Connected() {
+		t.Error("The connector reconnected but does not report itself as connected.")
+	}
+
+	//
+	// Tell the connector and the server to shutdown and then wait for them to finish.
+	//
+	chStopped, _ = connector.Stop()
+
+	<-chStopped
+
+	chStopped, _ = server.Stop()
+
+	<-chStopped
+}
+
+func TestConnectorBacksOffAfterDroppedConnections(t *testing.T) {
+	//
+	// Listen with a raw listener that accepts connections and then immediately drops them.
+	//
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("The listener failed to create. (Error: %s)", err)
+	}
+
+	defer ln.Close()
+
+	chAccepted := make(chan bool, 1024)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.Close()
+
+			chAccepted <- true
+		}
+	}()
+
+	//
+	// Start a connector against it and let it run for a while.
+	//
+	connector, err := CreateConnector(&ConnectorConfig{
+		Address:    ln.Addr().String(),
+		Delim:      '\x00',
+		MinBackoff: 40 * time.Millisecond,
+		MaxBackoff: 80 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("The connector failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := connector.Start()
+
+	<-chStarted
+
+	time.Sleep(400 * time.Millisecond)
+
+	chStopped, _ := connector.Stop()
+
+	<-chStopped
+
+	//
+	// Assert that the connector waited between each reconnection rather than redialing immediately.
+	// Each delay is at least 20ms (and at least 40ms once the backoff has grown), so no more than a
+	// dozen connections can have been made.
+	//
+	if accepted := len(chAccepted); accepted > 12 {
+		t.Errorf("The connector redialed without backing off. (Connections: %d)", accepted)
+	}
+}
@@ -0,0 +1,100 @@
+package tcp
+
+import (
+	"errors"
+	"io"
+	"net"
+)
+
+//
+// ErrProtocolViolation is returned (or wrapped) by framers and transports when a client violates
+// the protocol being spoken, causing it to be disconnected.
+//
+var ErrProtocolViolation = errors.New("the client violated the protocol")
+
+//
+// DisconnectReason describes why a client was disconnected.
+//
+type DisconnectReason int
+
+const (
+	DisconnectNone              DisconnectReason = iota // The client has not been disconnected.
+	DisconnectPeerClosed                                // The client closed the connection.
+	DisconnectReadError                                 // Reading from the connection failed.
+	DisconnectWriteError                                // Writing to the connection failed.
+	DisconnectIdleTimeout                               // The client did not send a complete message in time.
+	DisconnectClosed                                    // Server-side code closed the client.
+	DisconnectKicked                                    // The client was kicked.
+	DisconnectServerShutdown                            // The server was shut down.
+	DisconnectProtocolViolation                         // The client violated the protocol being spoken.
+	DisconnectOversizedMessage                          // The client sent a message that exceeds the maximum message size.
+	DisconnectSlowConsumer                              // The client was not reading as fast as messages were being sent to it.
+	DisconnectWriteTimeout                              // Writing to the connection did not complete in time.
+	DisconnectHandshakeFailed                           // The client did not complete its handshake, or did not complete it in time.
+	DisconnectHeartbeatTimeout                          // The client did not respond to a heartbeat in time.
+	DisconnectPanic                                     // A handler function panicked while dealing with the client.
+)
+
+//
+// String returns a printable representation of the disconnect reason.
+//
+func (o DisconnectReason) String() string {
+	switch o {
+	case DisconnectNone:
+		return "none"
+	case DisconnectPeerClosed:
+		return "peer closed"
+	case DisconnectReadError:
+		return "read error"
+	case DisconnectWriteError:
+		return "write error"
+	case DisconnectIdleTimeout:
+		return "idle timeout"
+	case DisconnectClosed:
+		return "closed"
+	case DisconnectKicked:
+		return "kicked"
+	case DisconnectServerShutdown:
+		return "server shutdown"
+	case DisconnectProtocolViolation:
+		return "protocol violation"
+	case DisconnectOversizedMessage:
+		return "oversized message"
+	case DisconnectSlowConsumer:
+		return "slow consumer"
+	case DisconnectWriteTimeout:
+		return "write timeout"
+	case DisconnectHandshakeFailed:
+		return "handshake failed"
+	case DisconnectHeartbeatTimeout:
+		return "heartbeat timeout"
+	case DisconnectPanic:
+		return "handler panic"
+	}
+
+	return "unknown"
+}
+
+//
+// readErrorReason determines the disconnect reason implied by an error encountered while reading
+// from a client.
+//
+func readErrorReason(err error) DisconnectReason {
+	if err == io.EOF {
+		return DisconnectPeerClosed
+	}
+
+	if errors.Is(err, ErrMessageTooLarge) {
+		return DisconnectOversizedMessage
+	}
+
+	if errors.Is(err, ErrProtocolViolation) {
+		return DisconnectProtocolViolation
+	}
+
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return DisconnectIdleTimeout
+	}
+
+	return DisconnectReadError
+}
@@ -0,0 +1,363 @@
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+//
+// Framer splits an inbound stream of bytes into discrete frames and encodes outbound payloads into
+// frames that the remote end will be able to split back apart.
+//
+type Framer interface {
+	//
+	// ReadFrame blocks until a complete frame has been read from the provided reader and then
+	// returns it. Any error returned by the underlying reader should be passed back as-is so that
+	// the caller can tell a clean disconnect (e.g. io.EOF) apart from a failure.
+	//
+	// If the provided maximum size is greater than zero and the payload of the frame being read (i.e.
+	// not counting any delimiters, headers, or other framing bytes) would exceed it, ReadFrame must
+	// stop without buffering the rest of the frame and return ErrMessageTooLarge. The reader must be
+	// left in a position from which SkipFrame can discard the remainder of the frame.
+	//
+	ReadFrame(reader *bufio.Reader, maxSize int) ([]byte, error)
+
+	//
+	// SkipFrame discards the remainder of a frame for which ReadFrame returned ErrMessageTooLarge,
+	// leaving the reader positioned at the start of the next frame. It must not buffer the discarded
+	// bytes.
+	//
+	SkipFrame(reader *bufio.Reader) error
+
+	//
+	// EncodeFrame returns the bytes that should be written to the wire in order to send the
+	// provided payload as a single frame. Implementations must not modify the provided payload.
+	//
+	EncodeFrame(pyld []byte) ([]byte, error)
+
+	//
+	// Payload returns the portion of a frame returned by ReadFrame that holds its actual payload
+	// (i.e. with any delimiters or other framing bytes that were left on it removed). Implementations
+	// should return a sub-slice of the provided frame rather than a copy.
+	//
+	Payload(frame []byte) []byte
+}
+
+//
+// ErrMessageTooLarge is returned by framers when a frame exceeds the maximum allowed message size.
+//
+var ErrMessageTooLarge = errors.New("the message exceeds the maximum allowed message size")
+
+//
+// OversizePolicy describes what a server should do when a client sends a message that exceeds the
+// server's configured maximum message size.
+//
+type OversizePolicy int
+
+const (
+	OversizeDisconnect OversizePolicy = iota // Disconnect the offending client. This is the default.
+	OversizeDiscard                          // Discard the offending message and resync to the start of the next one.
+	OversizeCallback                         // Discard the offending message, resync, and execute the "on oversized message" handler.
+)
+
+//
+// DelimFramer is a framer that splits frames on a single sentinel byte. This is the default framer
+// used by servers that are not configured with one explicitly.
+//
+// NOTE: Frames read by this framer retain their trailing delimiter.
+//
+type DelimFramer struct {
+	Delim byte // The byte that terminates each frame.
+}
+
+//
+// ReadFrame implements the method described by the Framer interface.
+//
+func (o *DelimFramer) ReadFrame(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	if maxSize <= 0 {
+		return reader.ReadBytes(o.Delim)
+	}
+
+	var frame []byte
+
+	for {
+		chunk, err := reader.ReadSlice(o.Delim)
+
+		//
+		// The delimiter (if it has been reached) is not part of the payload, so it does not count
+		// against the maximum size.
+		//
+		size := len(frame) + len(chunk)
+		if err == nil {
+			size--
+		}
+
+		if size > maxSize {
+			//
+			// If the delimiter was consumed along with the chunk, put it back so that a subsequent
+			// call to SkipFrame stops at the end of this frame rather than the next one.
+			//
+			if err == nil {
+				reader.UnreadByte()
+			}
+
+			return nil, ErrMessageTooLarge
+		}
+
+		frame = append(frame, chunk...)
+
+		if err != bufio.ErrBufferFull {
+			return frame, err
+		}
+	}
+}
+
+//
+// SkipFrame implements the method described by the Framer interface.
+//
+func (o *DelimFramer) SkipFrame(reader *bufio.Reader) error {
+	for {
+		_, err := reader.ReadSlice(o.Delim)
+
+		if err != bufio.ErrBufferFull {
+			return err
+		}
+	}
+}
+
+//
+// EncodeFrame implements the method described by the Framer interface.
+//
+func (o *DelimFramer) EncodeFrame(pyld []byte) ([]byte, error) {
+	frame := make([]byte, len(pyld)+1)
+
+	copy(frame, pyld)
+
+	frame[len(pyld)] = o.Delim
+
+	return frame, nil
+}
+
+//
+// Payload implements the method described by the Framer interface.
+//
+func (o *DelimFramer) Payload(frame []byte) []byte {
+	if len(frame) > 0 && frame[len(frame)-1] == o.Delim {
+		return frame[:len(frame)-1]
+	}
+
+	return frame
+}
+
+//
+// LengthPrefixFramer is a framer that precedes each frame with a fixed-width header holding the
+// length of the payload that follows it. Because no sentinel byte is involved, payloads may contain
+// arbitrary binary data.
+//
+// NOTE: Frames read by this framer do not include their header.
+//
+type LengthPrefixFramer struct {
+	Width     int              // The width of the length header in bytes. Must be 1, 2, 4, or 8.
+	ByteOrder binary.ByteOrder // The byte order of the length header. Defaults to big-endian.
+}
+
+//
+// ReadFrame implements the method described by the Framer interface.
+//
+func (o *LengthPrefixFramer) ReadFrame(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	//
+	// Only peek at the header until we know that the frame is small enough to accept so that the
+	// reader is still positioned at the start of the frame if SkipFrame needs to be called.
+	//
+	size, err := o.peekSize(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxSize > 0 && size > maxSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	reader.Discard(o.Width)
+
+	//
+	// Never trust the header enough to allocate the whole frame up front. A hostile peer could
+	// otherwise claim an enormous length and exhaust memory (or panic the allocator) with a single
+	// header. Instead, let the buffer grow only as the payload actually arrives.
+	//
+	var frame bytes.Buffer
+
+	if size <= lengthPrefixPreallocSize {
+		frame.Grow(size)
+	}
+
+	if _, err := io.CopyN(&frame, reader, int64(size)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	return frame.Bytes(), nil
+}
+
+//
+// frameSize returns how many bytes the provided frame, exactly as returned by the provided framer's
+// ReadFrame method, took up on the wire. Length-prefixed frames are returned without their headers,
+// so the header is added back in so that inbound and outbound traffic are counted the same way.
+//
+func frameSize(framer Framer, frame []byte) int {
+	if framer, ok := framer.(*LengthPrefixFramer); ok {
+		return framer.Width + len(frame)
+	}
+
+	return len(frame)
+}
+
+//
+// SkipFrame implements the method described by the Framer interface.
+//
+func (o *LengthPrefixFramer) SkipFrame(reader *bufio.Reader) error {
+	size, err := o.peekSize(reader)
+	if err != nil {
+		return err
+	}
+
+	if _, err := reader.Discard(o.Width + size); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+//
+// EncodeFrame implements the method described by the Framer interface.
+//
+func (o *LengthPrefixFramer) EncodeFrame(pyld []byte) ([]byte, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	if o.Width < 8 && uint64(len(pyld)) >= uint64(1)<<(8*uint(o.Width)) {
+		return nil, fmt.Errorf(
+			"a payload of %d bytes cannot be described by a %d byte length header", len(pyld), o.Width,
+		)
+	}
+
+	frame := make([]byte, o.Width+len(pyld))
+	order := o.byteOrder()
+
+	switch o.Width {
+	case 1:
+		frame[0] = byte(len(pyld))
+	case 2:
+		order.PutUint16(frame, uint16(len(pyld)))
+	case 4:
+		order.PutUint32(frame, uint32(len(pyld)))
+	case 8:
+		order.PutUint64(frame, uint64(len(pyld)))
+	}
+
+	copy(frame[o.Width:], pyld)
+
+	return frame, nil
+}
+
+//
+// Payload implements the method described by the Framer interface.
+//
+func (o *LengthPrefixFramer) Payload(frame []byte) []byte {
+	return frame
+}
+
+//
+// validate ensures that the framer has been configured with a supported header width.
+//
+func (o *LengthPrefixFramer) validate() error {
+	switch o.Width {
+	case 1, 2, 4, 8:
+		return nil
+	}
+
+	return fmt.Errorf("a length header width of %d bytes is not supported (must be 1, 2, 4, or 8)", o.Width)
+}
+
+//
+// byteOrder returns the byte order that the framer's length headers are encoded with.
+//
+func (o *LengthPrefixFramer) byteOrder() binary.ByteOrder {
+	if o.ByteOrder == nil {
+		return binary.BigEndian
+	}
+
+	return o.ByteOrder
+}
+
+//
+// peekSize decodes the payload length held by the header at the front of the provided reader
+// without consuming it.
+//
+func (o *LengthPrefixFramer) peekSize(reader *bufio.Reader) (int, error) {
+	if err := o.validate(); err != nil {
+		return 0, err
+	}
+
+	header, err := reader.Peek(o.Width)
+	if err != nil {
+		if err == io.EOF && len(header) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return 0, err
+	}
+
+	return o.decodeSize(header)
+}
+
+//
+// decodeSize decodes the payload length held by the provided header.
+//
+func (o *LengthPrefixFramer) decodeSize(header []byte) (int, error) {
+	var size uint64
+
+	order := o.byteOrder()
+
+	switch o.Width {
+	case 1:
+		size = uint64(header[0])
+	case 2:
+		size = uint64(order.Uint16(header))
+	case 4:
+		size = uint64(order.Uint32(header))
+	case 8:
+		size = order.Uint64(header)
+	default:
+		return 0, o.validate()
+	}
+
+	if size > uint64(maxInt-o.Width) {
+		return 0, ErrMessageTooLarge
+	}
+
+	return int(size), nil
+}
+
+//
+// lengthPrefixPreallocSize is the largest frame that LengthPrefixFramer will allocate a buffer for
+// before any of its payload has actually been read.
+//
+const lengthPrefixPreallocSize = 64 * 1024
+
+//
+// maxInt is the largest value that can be held by an int on the current platform.
+//
+const maxInt = int(^uint(0) >> 1)
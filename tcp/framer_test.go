@@ -0,0 +1,291 @@
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLengthPrefixFramerRoundTrip(t *testing.T) {
+	//
+	// Encode a binary payload that contains bytes that would trip up a delimiter-based framer with
+	// every supported header width and byte order, and then make sure that it reads back unchanged.
+	//
+	pyld := []byte("binary\n\x00payload\x00\n")
+
+	for _, width := range []int{1, 2, 4, 8} {
+		for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			framer := &LengthPrefixFramer{Width: width, ByteOrder: order}
+
+			frame, err := framer.EncodeFrame(pyld)
+			if err != nil {
+				t.Fatalf("Failed to encode a frame. (Width: %d) (Error: %s)", width, err)
+			}
+
+			if len(frame) != width+len(pyld) {
+				t.Errorf("An encoded frame was %d bytes long instead of %d.", len(frame), width+len(pyld))
+			}
+
+			read, err := framer.ReadFrame(bufio.NewReader(bytes.NewReader(frame)), 0)
+			if err != nil {
+				t.Fatalf("Failed to read a frame. (Width: %d) (Error: %s)", width, err)
+			}
+
+			if !bytes.Equal(read, pyld) {
+				t.Errorf("A frame was read, but it was not equal to what was encoded. (Width: %d)", width)
+			}
+		}
+	}
+}
+
+func TestLengthPrefixFramerSurvivesHostileHeader(t *testing.T) {
+	//
+	// Feed headers that claim absurd lengths but are followed by only a handful of bytes, and make
+	// sure that the framer reports a truncated frame rather than trying to allocate the claimed
+	// length up front.
+	//
+	cases := []struct {
+		width  int
+		header []byte
+	}{
+		{8, []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{4, []byte{0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, c := range cases {
+		framer := &LengthPrefixFramer{Width: c.width}
+		stream := append(c.header, []byte("short")...)
+
+		_, err := framer.ReadFrame(bufio.NewReader(bytes.NewReader(stream)), 0)
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("A truncated frame with a hostile header was not rejected. (Width: %d) (Error: %v)", c.width, err)
+		}
+	}
+}
+
+func TestLengthPrefixFramerRejectsOversizedPayload(t *testing.T) {
+	framer := &LengthPrefixFramer{Width: 1}
+
+	if _, err := framer.EncodeFrame(make([]byte, 256)); err == nil {
+		t.Error("A payload too large for a one byte length header was encoded without error.")
+	}
+}
+
+func TestLengthPrefixFramerRejectsUnsupportedWidth(t *testing.T) {
+	_, err := CreateServer(&ServerConfig{
+		Address: "127.0.0.1:0",
+		Framer:  &LengthPrefixFramer{Width: 3},
+	})
+	if err == nil {
+		t.Error("A server was created with an unsupported length header width.")
+	}
+}
+
+func TestDelimFramerPayloadStripsDelimiter(t *testing.T) {
+	framer := &DelimFramer{Delim: '\n'}
+
+	frame, err := framer.ReadFrame(bufio.NewReader(bytes.NewReader([]byte("hello\nworld\n"))), 0)
+	if err != nil {
+		t.Fatalf("Failed to read a frame. (Error: %s)", err)
+	}
+
+	if string(frame) != "hello\n" {
+		t.Errorf("A frame was read, but it did not retain its delimiter. (Got: %q)", frame)
+	}
+
+	if string(framer.Payload(frame)) != "hello" {
+		t.Errorf("A frame's payload still contained its delimiter. (Got: %q)", framer.Payload(frame))
+	}
+}
+
+func TestDelimFramerEncodeAppendsDelimiter(t *testing.T) {
+	framer := &DelimFramer{Delim: '\n'}
+	pyld := []byte("hello")
+
+	frame, err := framer.EncodeFrame(pyld)
+	if err != nil {
+		t.Fatalf("Failed to encode a frame. (Error: %s)", err)
+	}
+
+	if string(frame) != "hello\n" {
+		t.Errorf("An encoded frame did not end with the delimiter. (Got: %q)", frame)
+	}
+
+	if string(pyld) != "hello" {
+		t.Errorf("Encoding a frame modified the provided payload. (Got: %q)", pyld)
+	}
+}
+
+func TestServerUsesConfiguredFramer(t *testing.T) {
+	//
+	// Create a new server that splits messages into fixed-size chunks and echoes each one back.
+	//
+	chMessage := make(chan string, 2)
+
+	server, err := CreateServer(&ServerConfig{
+		Address: "127.0.0.1:0",
+		Framer:  &fixedFramer{size: 4},
+		OnNewMessage: func(c *Client, message string) {
+			chMessage <- message
+
+			c.Send(message)
+		},
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	//
+	// Assert that the stream was split by the custom framer in both directions.
+	//
+	conn.Write([]byte("abcdefgh"))
+
+	for _, expected := range []string{"abcd", "efgh"} {
+		select {
+		case message := <-chMessage:
+			if message != expected {
+				t.Errorf("A message was not split by the configured framer. (Got: %q)", message)
+			}
+		case <-time.After(1 * time.Second):
+			t.Fatal("The \"OnNewMessage\" event handler never fired.")
+		}
+	}
+
+	buf := make([]byte, 8)
+
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "abcdefgh" {
+		t.Errorf("The echoed messages were not encoded by the configured framer. (Got: %q) (Error: %v)", buf, err)
+	}
+
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestFramersLimitPayloadSize(t *testing.T) {
+	//
+	// Make sure that a payload of exactly the maximum size is accepted by every framer, regardless
+	// of how many framing bytes surround it.
+	//
+	pyld := []byte("12345678")
+
+	for _, framer := range []Framer{&DelimFramer{Delim: '\n'}, &LengthPrefixFramer{Width: 4}} {
+		frame, _ := framer.EncodeFrame(pyld)
+
+		read, err := framer.ReadFrame(bufio.NewReader(bytes.NewReader(frame)), len(pyld))
+		if err != nil {
+			t.Errorf("A payload of exactly the maximum size was rejected. (Framer: %T) (Error: %s)", framer, err)
+		} else if !bytes.Equal(framer.Payload(read), pyld) {
+			t.Errorf("A payload was not read correctly. (Framer: %T) (Got: %q)", framer, read)
+		}
+
+		if _, err := framer.ReadFrame(bufio.NewReader(bytes.NewReader(frame)), len(pyld)-1); err != ErrMessageTooLarge {
+			t.Errorf("A payload over the maximum size was not rejected. (Framer: %T) (Error: %v)", framer, err)
+		}
+	}
+}
+
+func TestFramersResyncAfterOversizedFrame(t *testing.T) {
+	//
+	// Build a stream for each framer holding an oversized frame followed by a small one, and make
+	// sure that the oversized frame is rejected and that the small one can still be read after
+	// skipping past it.
+	//
+	lengthPrefixFramer := &LengthPrefixFramer{Width: 4}
+	big, _ := lengthPrefixFramer.EncodeFrame(make([]byte, 64))
+	small, _ := lengthPrefixFramer.EncodeFrame([]byte("ok"))
+
+	cases := []struct {
+		framer Framer
+		stream []byte
+	}{
+		{&DelimFramer{Delim: '\n'}, []byte("this line is far too long to be accepted\nok\n")},
+		{&DelimFramer{Delim: '\n'}, append(bytes.Repeat([]byte("x"), 8192), []byte("\nok\n")...)},
+		{lengthPrefixFramer, append(big, small...)},
+	}
+
+	for i, c := range cases {
+		reader := bufio.NewReader(bytes.NewReader(c.stream))
+
+		if _, err := c.framer.ReadFrame(reader, 16); err != ErrMessageTooLarge {
+			t.Fatalf("An oversized frame was not rejected. (Case: %d) (Error: %v)", i, err)
+		}
+
+		if err := c.framer.SkipFrame(reader); err != nil {
+			t.Fatalf("Failed to skip an oversized frame. (Case: %d) (Error: %s)", i, err)
+		}
+
+		frame, err := c.framer.ReadFrame(reader, 16)
+		if err != nil {
+			t.Fatalf("Failed to read a frame after skipping. (Case: %d) (Error: %s)", i, err)
+		}
+
+		if string(c.framer.Payload(frame)) != "ok" {
+			t.Errorf("The frame after an oversized one was not read correctly. (Case: %d) (Got: %q)", i, frame)
+		}
+	}
+}
+
+//
+// fixedFramer is a framer used by tests that treats every run of a fixed number of bytes as a frame.
+//
+type fixedFramer struct {
+	size int // The number of bytes in every frame.
+}
+
+//
+// ReadFrame implements the method described by the Framer interface.
+//
+func (o *fixedFramer) ReadFrame(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	if maxSize > 0 && o.size > maxSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	frame := make([]byte, o.size)
+
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+//
+// SkipFrame implements the method described by the Framer interface.
+//
+func (o *fixedFramer) SkipFrame(reader *bufio.Reader) error {
+	_, err := reader.Discard(o.size)
+
+	return err
+}
+
+//
+// EncodeFrame implements the method described by the Framer interface.
+//
+func (o *fixedFramer) EncodeFrame(pyld []byte) ([]byte, error) {
+	return append([]byte(nil), pyld...), nil
+}
+
+//
+// Payload implements the method described by the Framer interface.
+//
+func (o *fixedFramer) Payload(frame []byte) []byte {
+	return frame
+}
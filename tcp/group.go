@@ -0,0 +1,146 @@
+package tcp
+
+import (
+	"sort"
+)
+
+//
+// JoinGroup adds the specified client to the named group, creating the group if it does not yet
+// exist. Clients automatically leave every group that they are a member of when they disconnect.
+// ErrClientClosed is returned if the client has already disconnected.
+//
+func (o *Server) JoinGroup(group string, client *Client) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.clients[client.ID()] != client {
+		return ErrClientClosed
+	}
+
+	members, ok := o.groups[group]
+	if !ok {
+		members = make(map[int]*Client)
+		o.groups[group] = members
+	}
+
+	members[client.ID()] = client
+	client.groups[group] = true
+
+	return nil
+}
+
+//
+// LeaveGroup removes the specified client from the named group (if it is a member). The group is
+// discarded once its last member leaves.
+//
+func (o *Server) LeaveGroup(group string, client *Client) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.leaveGroupLocked(group, client)
+}
+
+//
+// GroupMembers returns a snapshot of the members of the named group, ordered by client id.
+//
+func (o *Server) GroupMembers(group string) []*Client {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	members := make([]*Client, 0, len(o.groups[group]))
+	for _, client := range o.groups[group] {
+		members = append(members, client)
+	}
+
+	sort.Slice(members, func(i, j int) bool { return members[i].ID() < members[j].ID() })
+
+	return members
+}
+
+//
+// Groups returns the sorted names of every group that currently has at least one member.
+//
+func (o *Server) Groups() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	groups := make([]string, 0, len(o.groups))
+	for group := range o.groups {
+		groups = append(groups, group)
+	}
+
+	sort.Strings(groups)
+
+	return groups
+}
+
+//
+// SendToGroup sends the specified message to every member of the named group. See
+// SendBytesToGroup for details.
+//
+func (o *Server) SendToGroup(group string, msg string) error {
+	return o.SendBytesToGroup(group, []byte(msg))
+}
+
+//
+// SendBytesToGroup sends the specified bytes to every member of the named group, just as
+// SendBytesAll does for every connected client. If the send fails for any member, a
+// *BroadcastError listing each failure is returned.
+//
+func (o *Server) SendBytesToGroup(group string, pyld []byte) error {
+	frame, err := o.framer.EncodeFrame(pyld)
+	if err != nil {
+		return err
+	}
+
+	return o.broadcast(o.GroupMembers(group), frame)
+}
+
+//
+// Join adds the client to the named group on its server. See Server.JoinGroup for details.
+//
+func (o *Client) Join(group string) error {
+	return o.server.JoinGroup(group, o)
+}
+
+//
+// Leave removes the client from the named group on its server.
+//
+func (o *Client) Leave(group string) {
+	o.server.LeaveGroup(group, o)
+}
+
+//
+// Groups returns the sorted names of every group that the client is a member of.
+//
+func (o *Client) Groups() []string {
+	o.server.mu.Lock()
+	defer o.server.mu.Unlock()
+
+	groups := make([]string, 0, len(o.groups))
+	for group := range o.groups {
+		groups = append(groups, group)
+	}
+
+	sort.Strings(groups)
+
+	return groups
+}
+
+//
+// leaveGroupLocked removes the specified client from the named group. The caller must hold the
+// server's lock.
+//
+func (o *Server) leaveGroupLocked(group string, client *Client) {
+	members, ok := o.groups[group]
+	if !ok || members[client.ID()] != client {
+		return
+	}
+
+	delete(members, client.ID())
+	delete(client.groups, group)
+
+	if len(members) == 0 {
+		delete(o.groups, group)
+	}
+}
@@ -0,0 +1,106 @@
+package tcp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGroups(t *testing.T) {
+	//
+	// Create a new server that puts every client that sends "join" into a lobby group.
+	//
+	chJoined := make(chan bool, 1)
+	chConnectionClosed := make(chan bool, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address: "127.0.0.1:0",
+		Delim:   '\x00',
+		OnNewMessage: func(c *Client, message string) {
+			if message == "join\x00" {
+				c.Join("lobby")
+
+				chJoined <- true
+			}
+		},
+		OnClientConnectionClosed: func(c *Client) { chConnectionClosed <- true },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Connect two clients, only one of which joins the lobby.
+	//
+	member, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	outsider, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer outsider.Close()
+
+	member.Write([]byte("join\x00"))
+
+	<-chJoined
+
+	if groups := server.Groups(); len(groups) != 1 || groups[0] != "lobby" {
+		t.Errorf("The server does not report the expected groups. (Got: %v)", groups)
+	}
+
+	//
+	// Send to the lobby and assert that only its member receives the message.
+	//
+	if err := server.SendToGroup("lobby", "hi"); err != nil {
+		t.Fatalf("Sending to the group failed. (Error: %s)", err)
+	}
+
+	buf := make([]byte, 3)
+
+	member.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	if n, err := member.Read(buf); err != nil || string(buf[:n]) != "hi\x00" {
+		t.Errorf("The group member did not receive the message. (Got: %q) (Error: %v)", buf[:n], err)
+	}
+
+	outsider.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+
+	if n, _ := outsider.Read(buf); n > 0 {
+		t.Errorf("A client outside of the group received a message sent to it. (Got: %q)", buf[:n])
+	}
+
+	//
+	// Disconnect the member and assert that the group is discarded along with it.
+	//
+	// NOTE: The client is only forgotten after the "OnClientConnectionClosed" event handler returns
+	//  (so that the handler can still see its groups), so give that a moment to happen.
+	//
+	member.Close()
+
+	<-chConnectionClosed
+
+	time.Sleep(10 * time.Millisecond)
+
+	if members := server.GroupMembers("lobby"); len(members) != 0 {
+		t.Errorf("A disconnected client is still a member of a group. (Members: %v)", members)
+	}
+
+	if groups := server.Groups(); len(groups) != 0 {
+		t.Errorf("An empty group was not discarded. (Groups: %v)", groups)
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
@@ -0,0 +1,195 @@
+package tcp
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+//
+// Default values for the optional heartbeat attributes of a server configuration.
+//
+// NOTE: Like any other message, the heartbeat messages are encoded by the server's framer (e.g.
+//  followed by the delimiter when using a DelimFramer).
+//
+const (
+	DefaultHeartbeatPing = "ping"
+	DefaultHeartbeatPong = "pong"
+)
+
+//
+// ErrHeartbeatTimeout is the error recorded against a client that did not answer a heartbeat ping
+// (or send anything else) in time.
+//
+var ErrHeartbeatTimeout = errors.New("the client did not respond to a heartbeat in time")
+
+//
+// RTT returns the round-trip time most recently measured by the heartbeat, or zero if none has
+// been measured yet.
+//
+func (o *Client) RTT() time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.rtt
+}
+
+//
+// LastSeen returns when a message was last received from the client, or when the client connected
+// if it has not sent anything yet.
+//
+func (o *Client) LastSeen() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.lastSeen
+}
+
+//
+// heartbeat periodically pings the client until it is stopped, disconnecting it if it does not
+// answer (or send anything else) within the heartbeat timeout. It is intended to be run in its own
+// goroutine per connected client.
+//
+func (o *Client) heartbeat(chHeartbeatDone chan<- bool) {
+	defer func() {
+		chHeartbeatDone <- true
+	}()
+
+	ping, err := o.framer.EncodeFrame(o.server.heartbeatPing())
+	if err != nil {
+		o.server.logger.Error("Failed to encode the heartbeat ping.", o.logFields("error", err)...)
+
+		return
+	}
+
+	ticker := time.NewTicker(o.server.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	var chExpired <-chan time.Time
+	var sentAt time.Time
+
+	for {
+		select {
+		case <-ticker.C:
+			//
+			// Only keep one ping outstanding at a time so that each pong can be matched up with the
+			// ping that it answers. A ping that has already been answered is no longer outstanding,
+			// even if its timeout has yet to fire.
+			//
+			if chExpired != nil {
+				o.mu.Lock()
+				answered := o.pingSentAt.IsZero()
+				o.mu.Unlock()
+
+				if !answered {
+					continue
+				}
+
+				chExpired = nil
+			}
+
+			select {
+			case o.chSend <- ping:
+				sentAt = time.Now()
+
+				o.mu.Lock()
+				o.pingSentAt = sentAt
+				o.mu.Unlock()
+
+				chExpired = time.After(o.server.heartbeatTimeout())
+
+			default:
+				//
+				// The client's outbound queue is full, so there is no point in piling a ping on top of
+				// it. Its slow consumer policy will deal with it.
+				//
+			}
+
+		case <-chExpired:
+			chExpired = nil
+
+			if o.LastSeen().After(sentAt) {
+				continue
+			}
+
+			o.server.logger.Warn(
+				"Disconnecting the TCP/IP client because it did not respond to a heartbeat in time.",
+				o.logFields("timeout", o.server.heartbeatTimeout())...,
+			)
+
+			o.closeWithReason(DisconnectHeartbeatTimeout, ErrHeartbeatTimeout)
+
+			return
+
+		case <-o.chStop:
+			return
+		}
+	}
+}
+
+//
+// handleHeartbeat answers or measures the provided frame if it is a heartbeat message. Returns
+// whether or not the frame was a heartbeat message (in which case it should not be handed to the
+// registered event handlers).
+//
+func (o *Client) handleHeartbeat(frame []byte) bool {
+	if o.server.config.HeartbeatInterval <= 0 {
+		return false
+	}
+
+	now := time.Now()
+
+	pyld := o.framer.Payload(frame)
+
+	switch {
+	case bytes.Equal(pyld, o.server.heartbeatPong()):
+		o.mu.Lock()
+		if !o.pingSentAt.IsZero() {
+			o.rtt = now.Sub(o.pingSentAt)
+			o.pingSentAt = time.Time{}
+		}
+		o.mu.Unlock()
+
+		return true
+
+	case bytes.Equal(pyld, o.server.heartbeatPing()):
+		o.SendBytes(o.server.heartbeatPong())
+
+		return true
+	}
+
+	return false
+}
+
+//
+// heartbeatTimeout returns how long clients have to answer a heartbeat ping.
+//
+func (o *Server) heartbeatTimeout() time.Duration {
+	if o.config.HeartbeatTimeout <= 0 {
+		return o.config.HeartbeatInterval
+	}
+
+	return o.config.HeartbeatTimeout
+}
+
+//
+// heartbeatPing returns the payload of the server's heartbeat pings.
+//
+func (o *Server) heartbeatPing() []byte {
+	if len(o.config.HeartbeatPing) == 0 {
+		return []byte(DefaultHeartbeatPing)
+	}
+
+	return o.config.HeartbeatPing
+}
+
+//
+// heartbeatPong returns the payload that clients answer the server's heartbeat pings with.
+//
+func (o *Server) heartbeatPong() []byte {
+	if len(o.config.HeartbeatPong) == 0 {
+		return []byte(DefaultHeartbeatPong)
+	}
+
+	return o.config.HeartbeatPong
+}
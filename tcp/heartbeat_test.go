@@ -0,0 +1,179 @@
+package tcp
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatMeasuresRTT(t *testing.T) {
+	//
+	// Create a new server that pings its clients frequently.
+	//
+	chNewClient := make(chan *Client, 1)
+	chMessage := make(chan string, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:           "127.0.0.1:0",
+		Delim:             '\n',
+		HeartbeatInterval: 50 * time.Millisecond,
+		OnNewClient:       func(c *Client) { chNewClient <- c },
+		OnNewMessage:      func(c *Client, msg string) { chMessage <- msg },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	client := <-chNewClient
+
+	//
+	// Answer a ping and assert that a round-trip time was measured and that the pong was not handed
+	// to the message handler.
+	//
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != DefaultHeartbeatPing+"\n" {
+		t.Fatalf("Expected a heartbeat ping. (Got: %q) (Error: %v)", line, err)
+	}
+
+	conn.Write([]byte(DefaultHeartbeatPong + "\n"))
+
+	time.Sleep(20 * time.Millisecond)
+
+	if client.RTT() <= 0 {
+		t.Error("No round-trip time was measured after answering a heartbeat ping.")
+	}
+
+	select {
+	case msg := <-chMessage:
+		t.Errorf("A heartbeat pong was handed to the message handler. (Message: %q)", msg)
+	default:
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestHeartbeatDisconnectsUnresponsiveClient(t *testing.T) {
+	//
+	// Create a new server that pings its clients frequently and tells us why they were disconnected.
+	//
+	chDisconnected := make(chan DisconnectReason, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:              "127.0.0.1:0",
+		Delim:                '\n',
+		HeartbeatInterval:    50 * time.Millisecond,
+		OnClientDisconnected: func(c *Client, reason DisconnectReason) { chDisconnected <- reason },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Connect, but never answer any pings.
+	//
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	select {
+	case reason := <-chDisconnected:
+		if reason != DisconnectHeartbeatTimeout {
+			t.Errorf("An unresponsive client was disconnected for the wrong reason. (Reason: %s)", reason)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("An unresponsive client was never disconnected.")
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestHeartbeatPingsEveryInterval(t *testing.T) {
+	//
+	// Create a new server that pings its clients frequently.
+	//
+	interval := 50 * time.Millisecond
+
+	server, err := CreateServer(&ServerConfig{
+		Address:           "127.0.0.1:0",
+		Delim:             '\n',
+		HeartbeatInterval: interval,
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	//
+	// Answer every ping right away and assert that answered pings do not hold up the next one.
+	//
+	reader := bufio.NewReader(conn)
+	deadline := time.Now().Add(6*interval + interval/2)
+	pings := 0
+
+	conn.SetReadDeadline(deadline)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+
+		if line == DefaultHeartbeatPing+"\n" {
+			pings++
+
+			conn.Write([]byte(DefaultHeartbeatPong + "\n"))
+		}
+	}
+
+	if pings < 5 {
+		t.Errorf("Answered heartbeat pings were not sent every interval. (Pings: %d)", pings)
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
@@ -0,0 +1,138 @@
+package tcp
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+//
+// Logger is a leveled, structured logger that servers and connectors report their lifecycle events
+// to. Each event is described by a message and any number of alternating key/value pairs (e.g.
+// "client_id", 1, "reason", DisconnectKicked).
+//
+// NOTE: The method set mirrors that of *slog.Logger, so one may be used directly.
+//
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+//
+// DefaultLogger is the logger used by servers and connectors that have not been configured with
+// one. It writes to the standard logger.
+//
+var DefaultLogger Logger = StdLogger(nil)
+
+//
+// StdLogger returns a logger that writes each event to the provided standard library logger as a
+// single line of text. Nil writes to the standard logger.
+//
+func StdLogger(logger *log.Logger) Logger {
+	return &stdLogger{logger: logger}
+}
+
+//
+// NopLogger returns a logger that discards everything. It is handy for silencing servers in tests.
+//
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+//
+// stdLogger adapts a standard library logger into a Logger.
+//
+type stdLogger struct {
+	logger *log.Logger // The logger to write to, or nil to write to the standard logger.
+}
+
+//
+// Debug implements the method described by the Logger interface.
+//
+func (o *stdLogger) Debug(msg string, keyvals ...interface{}) {
+	o.output("DEBUG", msg, keyvals)
+}
+
+//
+// Info implements the method described by the Logger interface.
+//
+func (o *stdLogger) Info(msg string, keyvals ...interface{}) {
+	o.output("INFO", msg, keyvals)
+}
+
+//
+// Warn implements the method described by the Logger interface.
+//
+func (o *stdLogger) Warn(msg string, keyvals ...interface{}) {
+	o.output("WARN", msg, keyvals)
+}
+
+//
+// Error implements the method described by the Logger interface.
+//
+func (o *stdLogger) Error(msg string, keyvals ...interface{}) {
+	o.output("ERROR", msg, keyvals)
+}
+
+//
+// output formats an event as "[LEVEL] Message. (key: value) (key: value)" and writes it.
+//
+func (o *stdLogger) output(level string, msg string, keyvals []interface{}) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "[%s] %s", level, msg)
+
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			fmt.Fprintf(&b, " (%v: %v)", keyvals[i], keyvals[i+1])
+		} else {
+			fmt.Fprintf(&b, " (%v)", keyvals[i])
+		}
+	}
+
+	if o.logger == nil {
+		log.Print(b.String())
+	} else {
+		o.logger.Print(b.String())
+	}
+}
+
+//
+// nopLogger is a Logger that discards everything.
+//
+type nopLogger struct{}
+
+//
+// Debug implements the method described by the Logger interface.
+//
+func (nopLogger) Debug(msg string, keyvals ...interface{}) {}
+
+//
+// Info implements the method described by the Logger interface.
+//
+func (nopLogger) Info(msg string, keyvals ...interface{}) {}
+
+//
+// Warn implements the method described by the Logger interface.
+//
+func (nopLogger) Warn(msg string, keyvals ...interface{}) {}
+
+//
+// Error implements the method described by the Logger interface.
+//
+func (nopLogger) Error(msg string, keyvals ...interface{}) {}
+
+//
+// LoggerFor returns the provided logger, or the default logger if it is nil. Packages that build
+// other kinds of servers on top of this one can use it to log with the same logger that the server
+// will.
+//
+func LoggerFor(logger Logger) Logger {
+	if logger == nil {
+		return DefaultLogger
+	}
+
+	return logger
+}
@@ -0,0 +1,55 @@
+//go:build go1.21
+// +build go1.21
+
+package tcp
+
+import (
+	"log/slog"
+)
+
+//
+// SlogLogger returns a logger that writes each event to the provided structured logger. Nil writes
+// to the default structured logger.
+//
+func SlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &slogLogger{logger: logger}
+}
+
+//
+// slogLogger adapts a structured logger from the standard library into a Logger.
+//
+type slogLogger struct {
+	logger *slog.Logger // The logger to write to.
+}
+
+//
+// Debug implements the method described by the Logger interface.
+//
+func (o *slogLogger) Debug(msg string, keyvals ...interface{}) {
+	o.logger.Debug(msg, keyvals...)
+}
+
+//
+// Info implements the method described by the Logger interface.
+//
+func (o *slogLogger) Info(msg string, keyvals ...interface{}) {
+	o.logger.Info(msg, keyvals...)
+}
+
+//
+// Warn implements the method described by the Logger interface.
+//
+func (o *slogLogger) Warn(msg string, keyvals ...interface{}) {
+	o.logger.Warn(msg, keyvals...)
+}
+
+//
+// Error implements the method described by the Logger interface.
+//
+func (o *slogLogger) Error(msg string, keyvals ...interface{}) {
+	o.logger.Error(msg, keyvals...)
+}
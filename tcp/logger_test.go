@@ -0,0 +1,103 @@
+package tcp
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStdLoggerFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := StdLogger(log.New(&buf, "", 0))
+
+	logger.Warn("Something happened.", "client_id", 7, "reason", DisconnectKicked)
+
+	if line := buf.String(); line != "[WARN] Something happened. (client_id: 7) (reason: kicked)\n" {
+		t.Errorf("The standard logger wrote an unexpected line. (Got: %q)", line)
+	}
+}
+
+func TestServerLogsToConfiguredLogger(t *testing.T) {
+	logger := &recordingLogger{mu: &sync.Mutex{}}
+	chNewClient := make(chan bool, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:     "127.0.0.1:0",
+		Delim:       '\x00',
+		Logger:      logger,
+		OnNewClient: func(c *Client) { chNewClient <- true },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	<-chNewClient
+
+	time.Sleep(10 * time.Millisecond)
+
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+
+	//
+	// Assert that events about the client were tagged with its id.
+	//
+	if !logger.contains("A TCP/IP client has connected. client_id=") {
+		t.Errorf("The configured logger was not told about the client. (Events: %q)", logger.events)
+	}
+}
+
+//
+// recordingLogger is a Logger that remembers every event it is given.
+//
+type recordingLogger struct {
+	mu     *sync.Mutex
+	events []string
+}
+
+func (o *recordingLogger) Debug(msg string, keyvals ...interface{}) { o.record(msg, keyvals) }
+func (o *recordingLogger) Info(msg string, keyvals ...interface{})  { o.record(msg, keyvals) }
+func (o *recordingLogger) Warn(msg string, keyvals ...interface{})  { o.record(msg, keyvals) }
+func (o *recordingLogger) Error(msg string, keyvals ...interface{}) { o.record(msg, keyvals) }
+
+func (o *recordingLogger) record(msg string, keyvals []interface{}) {
+	event := msg
+
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		event += " " + keyvals[i].(string) + "=" + fmt.Sprint(keyvals[i+1])
+	}
+
+	o.mu.Lock()
+	o.events = append(o.events, event)
+	o.mu.Unlock()
+}
+
+func (o *recordingLogger) contains(prefix string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, e := range o.events {
+		if strings.HasPrefix(e, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
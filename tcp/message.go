@@ -0,0 +1,124 @@
+package tcp
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+)
+
+//
+// messageFramer is the framer used by servers whose connections carry whole messages (see
+// MessageConn). Each message that is read is presented to the server prefixed with its length, and
+// each frame that the server writes is unwrapped and sent as a single message.
+//
+var messageFramer = &LengthPrefixFramer{Width: 8, ByteOrder: binary.BigEndian}
+
+//
+// MessageTransport is a connection whose underlying transport natively preserves message
+// boundaries (e.g. UDP datagrams, sequenced packets, or WebSocket messages).
+//
+type MessageTransport interface {
+	net.Conn
+
+	//
+	// ReadMessage blocks until a whole message has been received and then returns it. The returned
+	// slice only needs to remain valid until the next call.
+	//
+	ReadMessage() ([]byte, error)
+
+	//
+	// WriteMessage sends the provided bytes as a single message. Implementations must not retain the
+	// provided slice.
+	//
+	WriteMessage(msg []byte) error
+}
+
+//
+// MessageConn adapts a message-oriented transport into the stream-oriented net.Conn that servers
+// read from and write to, so that each message flows through the exact same client lifecycle as a
+// message split out of a TCP/IP stream. Servers handing out message connections must be created
+// with CreateMessageServer.
+//
+type MessageConn struct {
+	MessageTransport
+
+	pending []byte // The unread remainder of the message currently being read, preceded by its length.
+}
+
+//
+// CreateMessageConn instantiates and returns a new message connection around the provided
+// transport.
+//
+func CreateMessageConn(transport MessageTransport) *MessageConn {
+	return &MessageConn{MessageTransport: transport}
+}
+
+//
+// CreateMessageServer creates a new server instance that uses the provided function to create the
+// listener that it accepts connections from, every one of which is expected to be a MessageConn.
+// Each message is treated as exactly one message, so the provided configuration's "Delim" and
+// "Framer" attributes are ignored.
+//
+func CreateMessageServer(config *ServerConfig, listenFunc ListenFunc) (*Server, error) {
+	//
+	// Work with a copy of the configuration so that the caller's copy is not mutated when we swap in
+	// the framer used to carry message boundaries through the connections.
+	//
+	msgConfig := *config
+	msgConfig.Framer = messageFramer
+
+	return CreateServerWithListener(&msgConfig, listenFunc)
+}
+
+//
+// Read implements the method described by the net.Conn interface. Each message is presented
+// preceded by its length so that the server's framer can recover message boundaries.
+//
+func (o *MessageConn) Read(b []byte) (int, error) {
+	if len(o.pending) == 0 {
+		msg, err := o.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+
+		o.pending, err = messageFramer.EncodeFrame(msg)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(b, o.pending)
+
+	o.pending = o.pending[n:]
+
+	return n, nil
+}
+
+//
+// Write implements the method described by the net.Conn interface. The provided bytes are expected
+// to hold whole frames encoded by the server's framer, each of which is sent as its own message.
+//
+func (o *MessageConn) Write(b []byte) (int, error) {
+	width := messageFramer.Width
+
+	for rest := b; len(rest) > 0; {
+		if len(rest) < width {
+			return 0, io.ErrShortWrite
+		}
+
+		size := binary.BigEndian.Uint64(rest)
+		if uint64(len(rest)-width) < size {
+			return 0, io.ErrShortWrite
+		}
+
+		end := width + int(size)
+
+		if err := o.WriteMessage(rest[width:end]); err != nil {
+			return 0, err
+		}
+
+		rest = rest[end:]
+	}
+
+	return len(b), nil
+}
@@ -0,0 +1,44 @@
+package tcp
+
+import (
+	"time"
+)
+
+//
+// Metrics is a sink that servers report their activity to. Implementations must be safe for
+// concurrent use, as they are called from every client's goroutines. See PrometheusMetrics for a
+// built-in implementation.
+//
+type Metrics interface {
+	ClientConnected()                           // A new connection was accepted.
+	ClientDisconnected(reason DisconnectReason) // A client was disconnected.
+	MessageReceived(size int)                   // A message of the provided size (in bytes, including delimiters or length headers) was read from a client.
+	MessageSent(size int)                       // A message of the provided size (in bytes, including delimiters or length headers) was written to a client.
+	SendFailed()                                // A message could not be queued for or written to a client.
+	AcceptFailed()                              // A temporary error occurred while accepting new connections.
+	HandlerDuration(duration time.Duration)     // The message handlers took the provided amount of time to handle a single message.
+}
+
+//
+// nopMetrics is a Metrics sink that discards everything.
+//
+type nopMetrics struct{}
+
+func (nopMetrics) ClientConnected()                           {}
+func (nopMetrics) ClientDisconnected(reason DisconnectReason) {}
+func (nopMetrics) MessageReceived(size int)                   {}
+func (nopMetrics) MessageSent(size int)                       {}
+func (nopMetrics) SendFailed()                                {}
+func (nopMetrics) AcceptFailed()                              {}
+func (nopMetrics) HandlerDuration(duration time.Duration)     {}
+
+//
+// metricsFor returns the provided metrics sink, or one that discards everything if it is nil.
+//
+func metricsFor(metrics Metrics) Metrics {
+	if metrics == nil {
+		return nopMetrics{}
+	}
+
+	return metrics
+}
@@ -0,0 +1,83 @@
+package tcp
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	//
+	// Create a new server that echoes every message it receives and reports to an in-memory set of
+	// metrics.
+	//
+	metrics := CreatePrometheusMetrics("packetsvr")
+	chDisconnected := make(chan bool, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:              "127.0.0.1:0",
+		Delim:                '\n',
+		Metrics:              metrics,
+		OnNewMessageBytes:    func(c *Client, pyld []byte) { c.SendBytes(pyld) },
+		OnClientDisconnected: func(c *Client, reason DisconnectReason) { chDisconnected <- true },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Send a message, wait for it to be echoed, and then hang up.
+	//
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	conn.Write([]byte("hello\n"))
+
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	if echo, err := bufio.NewReader(conn).ReadString('\n'); echo != "hello\n" {
+		t.Errorf("The message was not echoed. (Got: %q) (Error: %v)", echo, err)
+	}
+
+	conn.Close()
+
+	<-chDisconnected
+
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+
+	//
+	// Scrape the metrics and assert that the traffic was counted.
+	//
+	rec := httptest.NewRecorder()
+
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := ioutil.ReadAll(rec.Body)
+
+	for _, expected := range []string{
+		"packetsvr_connections_accepted_total 1\n",
+		"packetsvr_clients_active 0\n",
+		"packetsvr_messages_received_total 1\n",
+		"packetsvr_received_bytes_total 6\n",
+		"packetsvr_messages_sent_total 1\n",
+		"packetsvr_sent_bytes_total 6\n",
+		"packetsvr_disconnects_total{reason=\"peer_closed\"} 1\n",
+		"packetsvr_handler_duration_seconds_count 1\n",
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("The scraped metrics are missing %q. (Got: %s)", expected, body)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package tcp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//
+// DefaultHandlerDurationBuckets are the upper bounds (in seconds) of the buckets that handler
+// latencies are sorted into if no other buckets have been configured.
+//
+var DefaultHandlerDurationBuckets = []float64{
+	0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5,
+}
+
+//
+// PrometheusMetrics is a Metrics sink that keeps running totals in memory and serves them over HTTP
+// in the Prometheus text exposition format. A single instance may be shared by several servers.
+//
+type PrometheusMetrics struct {
+	//
+	// NOTE: The atomically accessed counters are kept at the top of the structure so that they are
+	//  64-bit aligned on 32-bit platforms.
+	//
+	connections      uint64 // Total connections accepted.
+	disconnects      uint64 // Total clients disconnected.
+	messagesReceived uint64 // Total messages read from clients.
+	bytesReceived    uint64 // Total bytes read from clients.
+	messagesSent     uint64 // Total messages written to clients.
+	bytesSent        uint64 // Total bytes written to clients.
+	sendErrors       uint64 // Total messages that could not be queued for or written to clients.
+	acceptErrors     uint64 // Total temporary errors encountered while accepting connections.
+
+	namespace     string                      // Prefix of every metric name.
+	mu            *sync.Mutex                 // Synchronizes access to the members below.
+	reasons       map[DisconnectReason]uint64 // Total clients disconnected, by reason.
+	buckets       []float64                   // Upper bounds (in seconds) of the handler latency buckets.
+	bucketCounts  []uint64                    // Number of handler latencies that fell into each bucket (non-cumulative).
+	handlerCount  uint64                      // Total handler latencies observed.
+	handlerSumSec float64                     // Sum of every handler latency observed, in seconds.
+}
+
+//
+// CreatePrometheusMetrics creates a new, empty set of metrics whose names are all prefixed with the
+// provided namespace (e.g. "packetsvr"). Handler latencies are sorted into the provided buckets, or
+// DefaultHandlerDurationBuckets if none are provided.
+//
+func CreatePrometheusMetrics(namespace string, buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultHandlerDurationBuckets
+	}
+
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &PrometheusMetrics{
+		namespace:    namespace,
+		mu:           &sync.Mutex{},
+		reasons:      make(map[DisconnectReason]uint64),
+		buckets:      sorted,
+		bucketCounts: make([]uint64, len(sorted)),
+	}
+}
+
+//
+// ClientConnected implements the method described by the Metrics interface.
+//
+func (o *PrometheusMetrics) ClientConnected() {
+	atomic.AddUint64(&o.connections, 1)
+}
+
+//
+// ClientDisconnected implements the method described by the Metrics interface.
+//
+func (o *PrometheusMetrics) ClientDisconnected(reason DisconnectReason) {
+	atomic.AddUint64(&o.disconnects, 1)
+
+	o.mu.Lock()
+	o.reasons[reason]++
+	o.mu.Unlock()
+}
+
+//
+// MessageReceived implements the method described by the Metrics interface.
+//
+func (o *PrometheusMetrics) MessageReceived(size int) {
+	atomic.AddUint64(&o.messagesReceived, 1)
+	atomic.AddUint64(&o.bytesReceived, uint64(size))
+}
+
+//
+// MessageSent implements the method described by the Metrics interface.
+//
+func (o *PrometheusMetrics) MessageSent(size int) {
+	atomic.AddUint64(&o.messagesSent, 1)
+	atomic.AddUint64(&o.bytesSent, uint64(size))
+}
+
+//
+// SendFailed implements the method described by the Metrics interface.
+//
+func (o *PrometheusMetrics) SendFailed() {
+	atomic.AddUint64(&o.sendErrors, 1)
+}
+
+//
+// AcceptFailed implements the method described by the Metrics interface.
+//
+func (o *PrometheusMetrics) AcceptFailed() {
+	atomic.AddUint64(&o.acceptErrors, 1)
+}
+
+//
+// HandlerDuration implements the method described by the Metrics interface.
+//
+func (o *PrometheusMetrics) HandlerDuration(duration time.Duration) {
+	seconds := duration.Seconds()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.handlerCount++
+	o.handlerSumSec += seconds
+
+	for i, bound := range o.buckets {
+		if seconds <= bound {
+			o.bucketCounts[i]++
+
+			break
+		}
+	}
+}
+
+//
+// ServeHTTP implements the method described by the http.Handler interface, rendering the metrics in
+// the Prometheus text exposition format.
+//
+func (o *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	o.WriteTo(w)
+}
+
+//
+// WriteTo writes the metrics to the provided writer in the Prometheus text exposition format.
+//
+func (o *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	//
+	// NOTE: Disconnects are loaded before connections so that the number of active clients can never
+	//  appear to be negative.
+	//
+	disconnects := atomic.LoadUint64(&o.disconnects)
+	connections := atomic.LoadUint64(&o.connections)
+
+	simple := []struct {
+		name  string // Name of the metric, without the namespace.
+		kind  string // Prometheus type of the metric.
+		help  string // Description of the metric.
+		value uint64 // Current value of the metric.
+	}{
+		{"connections_accepted_total", "counter", "Total connections accepted.", connections},
+		{"clients_active", "gauge", "Clients currently connected.", connections - disconnects},
+		{"messages_received_total", "counter", "Total messages read from clients.", atomic.LoadUint64(&o.messagesReceived)},
+		{"received_bytes_total", "counter", "Total bytes read from clients.", atomic.LoadUint64(&o.bytesReceived)},
+		{"messages_sent_total", "counter", "Total messages written to clients.", atomic.LoadUint64(&o.messagesSent)},
+		{"sent_bytes_total", "counter", "Total bytes written to clients.", atomic.LoadUint64(&o.bytesSent)},
+		{"send_errors_total", "counter", "Total messages that could not be sent to clients.", atomic.LoadUint64(&o.sendErrors)},
+		{"accept_errors_total", "counter", "Total temporary errors while accepting connections.", atomic.LoadUint64(&o.acceptErrors)},
+	}
+
+	for _, e := range simple {
+		name := o.name(e.name)
+
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, e.help, name, e.kind, name, e.value)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	//
+	// Write the disconnects broken down by reason, in a stable order.
+	//
+	name := o.name("disconnects_total")
+
+	fmt.Fprintf(&b, "# HELP %s Total clients disconnected, by reason.\n# TYPE %s counter\n", name, name)
+
+	reasons := make([]DisconnectReason, 0, len(o.reasons))
+	for reason := range o.reasons {
+		reasons = append(reasons, reason)
+	}
+
+	sort.Slice(reasons, func(i, j int) bool { return reasons[i] < reasons[j] })
+
+	for _, reason := range reasons {
+		label := strings.Replace(reason.String(), " ", "_", -1)
+
+		fmt.Fprintf(&b, "%s{reason=%q} %d\n", name, label, o.reasons[reason])
+	}
+
+	//
+	// Write the handler latency histogram. Prometheus expects cumulative bucket counts.
+	//
+	name = o.name("handler_duration_seconds")
+
+	fmt.Fprintf(&b, "# HELP %s Time taken to handle a single message.\n# TYPE %s histogram\n", name, name)
+
+	var cumulative uint64
+
+	for i, bound := range o.buckets {
+		cumulative += o.bucketCounts[i]
+
+		fmt.Fprintf(&b, "%s_bucket{le=\"%g\"} %d\n", name, bound, cumulative)
+	}
+
+	fmt.Fprintf(&b, "%s_bucket{le=\"+Inf\"} %d\n", name, o.handlerCount)
+	fmt.Fprintf(&b, "%s_sum %g\n", name, o.handlerSumSec)
+	fmt.Fprintf(&b, "%s_count %d\n", name, o.handlerCount)
+
+	n, err := io.WriteString(w, b.String())
+
+	return int64(n), err
+}
+
+//
+// name returns the fully-qualified name of the provided metric.
+//
+func (o *PrometheusMetrics) name(name string) string {
+	if len(o.namespace) == 0 {
+		return name
+	}
+
+	return o.namespace + "_" + name
+}
@@ -0,0 +1,254 @@
+package tcp
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+)
+
+//
+// DefaultPubSubPrefix is the prefix that identifies pub/sub control messages if no other prefix
+// has been configured.
+//
+// When pub/sub is enabled, clients manage their subscriptions by sending messages of the form
+// "{prefix}sub {pattern}" and "{prefix}unsub {pattern}". Published messages are delivered to
+// subscribers as "{prefix}pub {topic} {payload}", and malformed control messages are answered with
+// "{prefix}err {reason}".
+//
+const DefaultPubSubPrefix = "#"
+
+//
+// ErrInvalidTopic is returned when a topic or topic pattern is malformed.
+//
+var ErrInvalidTopic = errors.New("the topic or topic pattern is malformed")
+
+//
+// Subscribe subscribes the specified client to every topic matching the provided pattern. Topics
+// are dot-separated segments (e.g. "scores.soccer"), and a pattern segment of "*" matches exactly
+// one segment of a topic (e.g. "scores.*"). Clients are automatically unsubscribed from everything
+// when they disconnect. ErrClientClosed is returned if the client has already disconnected.
+//
+func (o *Server) Subscribe(pattern string, client *Client) error {
+	if !validTopic(pattern, true) {
+		return ErrInvalidTopic
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.clients[client.ID()] != client {
+		return ErrClientClosed
+	}
+
+	subscribers, ok := o.subscribers[pattern]
+	if !ok {
+		subscribers = make(map[int]*Client)
+		o.subscribers[pattern] = subscribers
+	}
+
+	subscribers[client.ID()] = client
+	client.subscriptions[pattern] = true
+
+	return nil
+}
+
+//
+// Unsubscribe removes the specified client's subscription to the provided pattern (if it has one).
+//
+func (o *Server) Unsubscribe(pattern string, client *Client) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.unsubscribeLocked(pattern, client)
+}
+
+//
+// Subscribers returns a snapshot of every client subscribed to a pattern matching the provided
+// topic, ordered by client id.
+//
+func (o *Server) Subscribers(topic string) []*Client {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	matched := make(map[int]*Client)
+
+	for pattern, subscribers := range o.subscribers {
+		if topicMatches(pattern, topic) {
+			for id, client := range subscribers {
+				matched[id] = client
+			}
+		}
+	}
+
+	clients := make([]*Client, 0, len(matched))
+	for _, client := range matched {
+		clients = append(clients, client)
+	}
+
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID() < clients[j].ID() })
+
+	return clients
+}
+
+//
+// Publish sends the provided payload to every client subscribed to a pattern matching the provided
+// topic. Each client receives the message at most once, even if several of its subscriptions match.
+// If the send fails for any subscriber, a *BroadcastError listing each failure is returned.
+//
+func (o *Server) Publish(topic string, pyld []byte) error {
+	if !validTopic(topic, false) {
+		return ErrInvalidTopic
+	}
+
+	msg := make([]byte, 0, len(o.pubSubPrefix())+len("pub ")+len(topic)+1+len(pyld))
+	msg = append(msg, o.pubSubPrefix()...)
+	msg = append(msg, "pub "...)
+	msg = append(msg, topic...)
+	msg = append(msg, ' ')
+	msg = append(msg, pyld...)
+
+	frame, err := o.framer.EncodeFrame(msg)
+	if err != nil {
+		return err
+	}
+
+	return o.broadcast(o.Subscribers(topic), frame)
+}
+
+//
+// Subscribe subscribes the client to the provided pattern on its server. See Server.Subscribe for
+// details.
+//
+func (o *Client) Subscribe(pattern string) error {
+	return o.server.Subscribe(pattern, o)
+}
+
+//
+// Unsubscribe removes the client's subscription to the provided pattern on its server.
+//
+func (o *Client) Unsubscribe(pattern string) {
+	o.server.Unsubscribe(pattern, o)
+}
+
+//
+// Subscriptions returns the sorted topic patterns that the client is subscribed to.
+//
+func (o *Client) Subscriptions() []string {
+	o.server.mu.Lock()
+	defer o.server.mu.Unlock()
+
+	patterns := make([]string, 0, len(o.subscriptions))
+	for pattern := range o.subscriptions {
+		patterns = append(patterns, pattern)
+	}
+
+	sort.Strings(patterns)
+
+	return patterns
+}
+
+//
+// handlePubSubControl handles the provided message payload if it is a pub/sub control message.
+// It returns whether or not the payload was a control message (and should therefore not be passed
+// along to the server's message handlers).
+//
+func (o *Server) handlePubSubControl(client *Client, pyld []byte) bool {
+	prefix := o.pubSubPrefix()
+
+	if !bytes.HasPrefix(pyld, []byte(prefix)) {
+		return false
+	}
+
+	command := strings.Fields(string(pyld[len(prefix):]))
+
+	var err error
+
+	switch {
+	case len(command) == 2 && command[0] == "sub":
+		err = o.Subscribe(command[1], client)
+
+	case len(command) == 2 && command[0] == "unsub":
+		o.Unsubscribe(command[1], client)
+
+	default:
+		err = errors.New("unknown control message")
+	}
+
+	if err != nil {
+		client.Send(prefix + "err " + err.Error())
+	}
+
+	return true
+}
+
+//
+// pubSubPrefix returns the prefix that identifies the server's pub/sub control messages.
+//
+func (o *Server) pubSubPrefix() string {
+	if len(o.config.PubSubPrefix) == 0 {
+		return DefaultPubSubPrefix
+	}
+
+	return o.config.PubSubPrefix
+}
+
+//
+// unsubscribeLocked removes the specified client's subscription to the provided pattern. The
+// caller must hold the server's lock.
+//
+func (o *Server) unsubscribeLocked(pattern string, client *Client) {
+	subscribers, ok := o.subscribers[pattern]
+	if !ok || subscribers[client.ID()] != client {
+		return
+	}
+
+	delete(subscribers, client.ID())
+	delete(client.subscriptions, pattern)
+
+	if len(subscribers) == 0 {
+		delete(o.subscribers, pattern)
+	}
+}
+
+//
+// validTopic reports whether the provided topic (or, if wildcards are allowed, topic pattern) is
+// well formed.
+//
+func validTopic(topic string, allowWildcards bool) bool {
+	if len(topic) == 0 || strings.ContainsAny(topic, " \t\r\n") {
+		return false
+	}
+
+	for _, segment := range strings.Split(topic, ".") {
+		if len(segment) == 0 {
+			return false
+		}
+
+		if strings.Contains(segment, "*") && (!allowWildcards || segment != "*") {
+			return false
+		}
+	}
+
+	return true
+}
+
+//
+// topicMatches reports whether the provided topic matches the provided pattern.
+//
+func topicMatches(pattern string, topic string) bool {
+	patternSegments := strings.Split(pattern, ".")
+	topicSegments := strings.Split(topic, ".")
+
+	if len(patternSegments) != len(topicSegments) {
+		return false
+	}
+
+	for i, segment := range patternSegments {
+		if segment != "*" && segment != topicSegments[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,91 @@
+package tcp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTopicMatches(t *testing.T) {
+	cases := []struct {
+		pattern string
+		topic   string
+		matches bool
+	}{
+		{"scores", "scores", true},
+		{"scores.*", "scores.soccer", true},
+		{"scores.*", "scores", false},
+		{"scores.*", "scores.soccer.final", false},
+		{"*.final", "scores.final", true},
+		{"scores.soccer", "scores.hockey", false},
+	}
+
+	for _, c := range cases {
+		if topicMatches(c.pattern, c.topic) != c.matches {
+			t.Errorf("Pattern %q matching topic %q should have been %t.", c.pattern, c.topic, c.matches)
+		}
+	}
+}
+
+func TestPublishToSubscribers(t *testing.T) {
+	//
+	// Create a new server with pub/sub enabled.
+	//
+	server, err := CreateServer(&ServerConfig{
+		Address: "127.0.0.1:0",
+		Delim:   '\n',
+		PubSub:  true,
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Connect a client and subscribe it to a wildcard pattern, waiting for the server to process the
+	// subscription.
+	//
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	conn.Write([]byte("#sub scores.*\n"))
+
+	for i := 0; i < 100 && len(server.Subscribers("scores.soccer")) == 0; i++ {
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	//
+	// Publish to a topic that does not match followed by one that does, and assert that only the
+	// latter is received.
+	//
+	if err := server.Publish("news.local", []byte("ignored")); err != nil {
+		t.Fatalf("Publishing failed. (Error: %s)", err)
+	}
+
+	if err := server.Publish("scores.soccer", []byte("1-0")); err != nil {
+		t.Fatalf("Publishing failed. (Error: %s)", err)
+	}
+
+	expected := "#pub scores.soccer 1-0\n"
+	buf := make([]byte, len(expected))
+
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != expected {
+		t.Errorf("The subscriber did not receive the expected message. (Got: %q) (Error: %v)", buf[:n], err)
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
@@ -0,0 +1,103 @@
+package tcp
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+//
+// PanicPolicy describes what a server should do when one of its registered handler functions
+// panics while dealing with a client.
+//
+type PanicPolicy int
+
+const (
+	PanicDisconnect PanicPolicy = iota // Recover, report the panic, and disconnect the offending client. This is the default.
+	PanicContinue                      // Recover, report the panic, and keep the offending client connected.
+)
+
+//
+// PanicError is reported to the "on error" handler when a registered handler function panics.
+//
+type PanicError struct {
+	Handler string      // The name of the handler function that panicked (e.g. "OnNewMessage").
+	Value   interface{} // The value that the handler function panicked with.
+	Stack   []byte      // The stack trace of the goroutine at the time of the panic.
+}
+
+//
+// Error implements the method described by the error interface.
+//
+func (o *PanicError) Error() string {
+	return fmt.Sprintf("the %s handler panicked: %v", o.Handler, o.Value)
+}
+
+//
+// OpError is reported to the "on error" handler when an operation that the server performs on its
+// own (e.g. accepting connections or reading from and writing to clients) fails.
+//
+type OpError struct {
+	Op  string // The operation that failed (i.e. "accept", "read", or "write").
+	Err error  // The error that the operation failed with.
+}
+
+//
+// Error implements the method described by the error interface.
+//
+func (o *OpError) Error() string {
+	return fmt.Sprintf("%s failed: %s", o.Op, o.Err)
+}
+
+//
+// Unwrap returns the error that the operation failed with.
+//
+func (o *OpError) Unwrap() error {
+	return o.Err
+}
+
+//
+// safely executes the provided function on behalf of the named handler function, recovering from
+// any panic, reporting it, and applying the server's panic policy to the provided client (if any).
+//
+func (o *Server) safely(client *Client, handler string, fn func()) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		err := &PanicError{Handler: handler, Value: r, Stack: debug.Stack()}
+
+		if client == nil {
+			o.logger.Error("A handler function panicked.", "handler", handler, "panic", r)
+		} else {
+			o.logger.Error("A handler function panicked.", client.logFields("handler", handler, "panic", r)...)
+		}
+
+		o.onError(client, err)
+
+		if client != nil && o.config.PanicPolicy == PanicDisconnect {
+			client.closeWithReason(DisconnectPanic, err)
+		}
+	}()
+
+	fn()
+}
+
+//
+// OnError executes the server's registered "on error" handler function. The client is nil for
+// errors that do not concern a particular client.
+//
+func (o *Server) onError(client *Client, err error) {
+	if o.config.OnError == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			o.logger.Error("The OnError handler function panicked.", "panic", r, "error", err)
+		}
+	}()
+
+	o.config.OnError(client, err)
+}
@@ -0,0 +1,85 @@
+package tcp
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPanicRecovery(t *testing.T) {
+	for _, policy := range []PanicPolicy{PanicDisconnect, PanicContinue} {
+		//
+		// Create a new server whose message handler panics on the first message, and that tells us
+		// about errors and disconnects.
+		//
+		chError := make(chan error, 2)
+		chMessage := make(chan string, 2)
+		chDisconnected := make(chan DisconnectReason, 1)
+
+		server, err := CreateServer(&ServerConfig{
+			Address:     "127.0.0.1:0",
+			Delim:       '\n',
+			PanicPolicy: policy,
+			OnNewMessage: func(c *Client, msg string) {
+				if msg == "boom\n" {
+					panic("kaboom")
+				}
+
+				chMessage <- msg
+			},
+			OnError:              func(c *Client, err error) { chError <- err },
+			OnClientDisconnected: func(c *Client, reason DisconnectReason) { chDisconnected <- reason },
+		})
+		if err != nil {
+			t.Fatalf("The server failed to create. (Error: %s)", err)
+		}
+
+		chStarted, _ := server.Start()
+
+		<-chStarted
+
+		conn, err := net.Dial("tcp", server.Addr().String())
+		if err != nil {
+			t.Fatal("Failed to connect to the test server.")
+		}
+
+		conn.Write([]byte("boom\nfine\n"))
+
+		//
+		// Assert that the panic was reported, and that the client was or was not disconnected
+		// according to the policy.
+		//
+		select {
+		case err := <-chError:
+			var panicErr *PanicError
+
+			if !errors.As(err, &panicErr) || panicErr.Handler != "OnNewMessage" || panicErr.Value != "kaboom" {
+				t.Errorf("The panic was reported incorrectly. (Error: %v)", err)
+			}
+		case <-time.After(1 * time.Second):
+			t.Fatal("The panic was never reported.")
+		}
+
+		if policy == PanicDisconnect {
+			if reason := <-chDisconnected; reason != DisconnectPanic {
+				t.Errorf("The panicking client was disconnected for the wrong reason. (Reason: %s)", reason)
+			}
+		} else {
+			select {
+			case msg := <-chMessage:
+				if msg != "fine\n" {
+					t.Errorf("An unexpected message was received after the panic. (Message: %q)", msg)
+				}
+			case <-time.After(1 * time.Second):
+				t.Error("The client was not kept around after the panic.")
+			}
+		}
+
+		conn.Close()
+
+		chStopped, _ := server.Stop()
+
+		<-chStopped
+	}
+}
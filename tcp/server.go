@@ -1,10 +1,11 @@
 package tcp
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
-	"log"
 	"net"
+	"sort"
 	"sync"
 	"time"
 )
@@ -13,61 +14,163 @@ import (
 // ServerConfig holds various configuration attributes for creating a new server.
 //
 type ServerConfig struct {
-	Address                  string                           // The bind "{address}:{port}" for the server's listener.
-	OnNewClient              func(client *Client)             // Handler function to execute when a new client connects.
-	OnClientConnectionClosed func(client *Client)             // Handler function to execute when a client disconnects. Do not expect connection to still be alive when executed.
-	OnNewMessage             func(client *Client, msg string) // Handler function to execute when a new message is recieved from a client.
-	Delim                    byte                             // The delimiter that should be expected when splitting packets up into messages.
+	Address                  string                                        // The bind "{address}:{port}" for the server's listener.
+	OnNewClient              func(client *Client)                          // Handler function to execute when a new client connects.
+	OnClientConnectionClosed func(client *Client)                          // Handler function to execute when a client disconnects. Do not expect connection to still be alive when executed.
+	OnClientDisconnected     func(client *Client, reason DisconnectReason) // Handler function to execute when a client disconnects, along with why. Executed right after the "on client connection closed" handler.
+	OnError                  func(client *Client, err error)               // Handler function to execute when a handler function panics (with a *PanicError) or an operation fails (with an *OpError). The client is nil for errors that do not concern a particular client.
+	OnNewMessage             func(client *Client, msg string)              // Handler function to execute when a new message is recieved from a client. The message is exactly as read by the framer (e.g. with its trailing delimiter).
+	OnNewMessageBytes        func(client *Client, pyld []byte)             // Handler function to execute with the raw payload of a new message, stripped of any framing bytes. The handler may retain the slice.
+	Delim                    byte                                          // The delimiter that should be expected when splitting packets up into messages. Ignored if a framer is provided.
+	Framer                   Framer                                        // The framer used to split packets up into messages. Defaults to a DelimFramer using Delim.
+	MaxMessageSize           int                                           // The maximum size in bytes of a single message's payload (not counting delimiters, headers, or other framing bytes). Zero means unlimited.
+	OversizePolicy           OversizePolicy                                // What to do when a client sends a message that exceeds the maximum message size.
+	OnOversizedMessage       func(client *Client)                          // Handler function to execute when a client sends an oversized message. Only used by the OversizeCallback policy.
+	SendQueueSize            int                                           // The number of outbound messages that may be queued for a single client. Defaults to DefaultSendQueueSize.
+	SlowConsumerPolicy       SlowConsumerPolicy                            // What to do when a message is sent to a client whose outbound queue is full.
+	OnSlowConsumer           func(client *Client)                          // Handler function to execute when a message is sent to a client whose outbound queue is full. Executed on the sending goroutine.
+	PanicPolicy              PanicPolicy                                   // What to do with a client when a handler function panics while dealing with it.
+	PubSub                   bool                                          // Whether clients may manage their own topic subscriptions using pub/sub control messages.
+	PubSubPrefix             string                                        // The prefix that identifies pub/sub control messages. Defaults to DefaultPubSubPrefix.
+	ReadIdleTimeout          time.Duration                                 // How long a client may go without sending a complete message before it is disconnected. Zero means forever.
+	WriteTimeout             time.Duration                                 // How long a single write to a client may take before it is disconnected. Zero means forever.
+	HandshakeTimeout         time.Duration                                 // How long a new client has to complete any transport handshake (e.g. TLS). Zero means forever.
+	HeartbeatInterval        time.Duration                                 // How often to send a heartbeat ping to each client. Zero disables heartbeats.
+	HeartbeatTimeout         time.Duration                                 // How long a client has to answer a heartbeat ping (or send anything else) before it is disconnected. Defaults to the heartbeat interval.
+	HeartbeatPing            []byte                                        // The payload of heartbeat pings. Defaults to DefaultHeartbeatPing. Clients may also send it to the server, which answers with a pong.
+	HeartbeatPong            []byte                                        // The payload that clients answer heartbeat pings with. Defaults to DefaultHeartbeatPong. Pings and pongs are never handed to the message handlers.
+	GoodbyeMessage           []byte                                        // Message sent to every client when the server is gracefully shut down. Nil sends nothing.
+	Logger                   Logger                                        // The logger that lifecycle events are reported to. Defaults to DefaultLogger. Use NopLogger() to silence the server.
+	Metrics                  Metrics                                       // The sink that activity (connections, traffic, errors, handler latency) is reported to. Nil reports nothing.
 }
 
+//
+// ErrClientNotFound is returned when attempting to address a client that is not connected.
+//
+var ErrClientNotFound = errors.New("no client with the specified id is connected")
+
+//
+// ListenFunc is a function that binds a listener to the provided address on behalf of a server.
+//
+type ListenFunc func(address string) (net.Listener, error)
+
 //
 // Server holds info about an actual server instance.
 //
 type Server struct {
-	mu           *sync.Mutex     // Synchronizes access to the client table.
-	config       *ServerConfig   // Basic configuration attributes of the server.
-	tlsConfig    *tls.Config     // Secure connection configuration attributes of the server. Only relevent when using TLS.
-	listener     net.Listener    // Actual listener that will bind to the configured address and await new connections.
-	clients      map[int]*Client // Holds each connected client.
-	nextClientID int             // Next valid client identifier that can be assigned to a new client.
-	chStarted    chan bool       // Channel that will be used to tell whoever cares that the server has completed startup.
-	chKill       chan bool       // Channel that will be used to tell the server's listener loop to stop.
-	chStopped    chan bool       // Channel that will be used to tell whoever cares that the server's listener loop has stopped.
+	mu           *sync.Mutex                // Synchronizes access to the client table and lifecycle state.
+	config       *ServerConfig              // Basic configuration attributes of the server.
+	tlsConfig    *tls.Config                // Secure connection configuration attributes of the server. Only relevent when using TLS.
+	framer       Framer                     // Framer used to split inbound streams into messages and to encode outbound ones.
+	logger       Logger                     // The logger that lifecycle events are reported to.
+	metrics      Metrics                    // The sink that activity is reported to.
+	listenFunc   ListenFunc                 // Custom function used to obtain the server's listener. Only relevent for non-TCP/IP transports.
+	listener     net.Listener               // Actual listener that will bind to the configured address and await new connections.
+	clients      map[int]*Client            // Holds each connected client.
+	groups       map[string]map[int]*Client // Holds the members of each named group of clients.
+	subscribers  map[string]map[int]*Client // Holds the subscribers of each topic pattern.
+	nextClientID int                        // Next valid client identifier that can be assigned to a new client.
+	chStarted    chan bool                  // Channel that will be used to tell whoever cares that the server has completed startup.
+	chKill       chan bool                  // Channel that will be used to tell the server's listener loop to stop.
+	chStopped    chan bool                  // Channel that is closed once the server's listener loop has stopped.
+	chShutdown   chan context.Context       // Channel that will be used to tell the server's listener loop to gracefully shut down.
+	shutdownErr  error                      // Describes what was cut off by the most recent graceful shutdown, if anything.
+	state        State                      // Where the server currently is in its lifecycle.
+	addr         net.Addr                   // The address that the server's listener is (or was most recently) bound to.
 }
 
 //
-// SendAll sends the specified message to all clients currently connected to the server.
+// SendAll sends the specified message to all clients currently connected to the server. See
+// SendBytesAll for details.
+//
+func (o *Server) SendAll(msg string) error {
+	return o.SendBytesAll([]byte(msg))
+}
+
+//
+// SendBytesAll sends the specified bytes to all clients currently connected to the server. The
+// payload is only encoded once, and it is sent to a snapshot of the client table taken when the
+// call begins, so clients that connect while it executes will not receive it. If the send fails
+// for any client, a *BroadcastError listing each failure is returned.
+//
+func (o *Server) SendBytesAll(pyld []byte) error {
+	frame, err := o.framer.EncodeFrame(pyld)
+	if err != nil {
+		return err
+	}
+
+	return o.broadcast(o.Clients(), frame)
+}
+
 //
-// NOTE: No synchronization is performed during this call. There is a chance that a send will be
-//  attempted to a client that disconnects while this call executes.
+// Client returns the connected client with the specified id, or nil if no such client is
+// connected.
 //
-func (o *Server) SendAll(msg string) {
-	o.SendBytesAll([]byte(msg))
+func (o *Server) Client(id int) *Client {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.clients[id]
 }
 
 //
-// SendBytesAll sends the specified bytes to all clients currently connected to the server.
+// ClientCount returns the number of clients currently connected to the server.
+//
+func (o *Server) ClientCount() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.clients)
+}
+
 //
-// NOTE: No synchronization is performed during this call. There is a chance that a send will be
-//  attempted to a client that disconnects while this call executes.
+// Clients returns a snapshot of the server's client table, ordered by client id, that can be
+// safely ranged over without holding the server's lock.
 //
-func (o *Server) SendBytesAll(pyld []byte) {
-	// TODO: If enough clients are connected that it would matter, spin off a couple of goroutines and
-	//  allocate them each a handful of the clients to send to.
+func (o *Server) Clients() []*Client {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 
+	clients := make([]*Client, 0, len(o.clients))
 	for _, client := range o.clients {
-		err := client.SendBytes(pyld)
-
-		if err != nil {
-			log.Printf(
-				"Failed to send \"send all\" message to a connected TCP/IP client. (Client: %s) (Hint: "+
-					"The client may have already disconnected.)",
-				client,
-			)
+		clients = append(clients, client)
+	}
+
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID() < clients[j].ID() })
+
+	return clients
+}
+
+//
+// ForEachClient executes the provided function for each client in a snapshot of the server's client
+// table, in order of client id, until the function returns false. The server's lock is not held
+// while the function executes, so it is free to call back into the server.
+//
+func (o *Server) ForEachClient(fn func(client *Client) bool) {
+	for _, client := range o.Clients() {
+		if !fn(client) {
+			return
 		}
 	}
 }
 
+//
+// Kick disconnects the connected client with the specified id, logging the provided reason. It
+// returns a channel that can optionally be blocked on if the caller would like to know when the
+// connection has been completely closed. ErrClientNotFound is returned if no such client is
+// connected.
+//
+func (o *Server) Kick(id int, reason string) (<-chan bool, error) {
+	client := o.Client(id)
+	if client == nil {
+		return nil, ErrClientNotFound
+	}
+
+	o.logger.Info("Kicking the TCP/IP client.", client.logFields("reason", reason)...)
+
+	return client.closeWithReason(DisconnectKicked, errors.New(reason)), nil
+}
+
 //
 // OnNewClient executes the server's registered "on new client" handler function.
 //
@@ -76,7 +179,7 @@ func (o *Server) onNewClient(client *Client) {
 		return
 	}
 
-	o.config.OnNewClient(client)
+	o.safely(client, "OnNewClient", func() { o.config.OnNewClient(client) })
 }
 
 //
@@ -88,60 +191,136 @@ func (o *Server) onClientConnectionClosed(client *Client) {
 		return
 	}
 
-	o.config.OnClientConnectionClosed(client)
+	o.safely(client, "OnClientConnectionClosed", func() { o.config.OnClientConnectionClosed(client) })
 }
 
 //
-// OnNewMessage executes the server's registered "on new message" handler function.
+// OnClientDisconnected executes the server's registered "on client disconnected" handler function.
 //
-func (o *Server) onNewMessage(client *Client, msg string) {
-	if o.config.OnNewMessage == nil {
+func (o *Server) onClientDisconnected(client *Client, reason DisconnectReason) {
+	if o.config.OnClientDisconnected == nil {
 		return
 	}
 
-	o.config.OnNewMessage(client, msg)
+	o.safely(client, "OnClientDisconnected", func() { o.config.OnClientDisconnected(client, reason) })
 }
 
 //
-// Start implements the method described by packetsvr.Server interface.
+// OnNewMessage executes the server's registered "on new message" handler functions.
+//
+func (o *Server) onNewMessage(client *Client, frame []byte) {
+	if o.config.PubSub && o.handlePubSubControl(client, o.framer.Payload(frame)) {
+		return
+	}
+
+	start := time.Now()
+	defer func() {
+		o.metrics.HandlerDuration(time.Since(start))
+	}()
+
+	if o.config.OnNewMessage != nil {
+		o.safely(client, "OnNewMessage", func() { o.config.OnNewMessage(client, string(frame)) })
+	}
+
+	if o.config.OnNewMessageBytes != nil {
+		o.safely(client, "OnNewMessageBytes", func() { o.config.OnNewMessageBytes(client, o.framer.Payload(frame)) })
+	}
+}
+
+//
+// OnOversizedMessage executes the server's registered "on oversized message" handler function.
+//
+func (o *Server) onOversizedMessage(client *Client) {
+	if o.config.OnOversizedMessage == nil {
+		return
+	}
+
+	o.safely(client, "OnOversizedMessage", func() { o.config.OnOversizedMessage(client) })
+}
+
+//
+// OnSlowConsumer executes the server's registered "on slow consumer" handler function.
+//
+func (o *Server) onSlowConsumer(client *Client) {
+	if o.config.OnSlowConsumer == nil {
+		return
+	}
+
+	o.safely(client, "OnSlowConsumer", func() { o.config.OnSlowConsumer(client) })
+}
+
+//
+// Start implements the method described by packetsvr.Server interface. A server that has stopped
+// may be started again. Starting a server that is already starting or running returns
+// ErrAlreadyRunning, and starting one that is still stopping returns ErrServerClosed.
 //
 func (o *Server) Start() (<-chan bool, error) {
 	//
 	// Log some debug info.
 	//
-	log.Print("Attempting to start the TCP/IP packet server...")
+	o.logger.Info("Attempting to start the TCP/IP packet server...")
 
 	//
-	// (Re)-initialize necessary members of the server structure.
+	// Make sure that the server is in a state that can be started, and (re)-initialize necessary
+	// members of the server structure.
 	//
+	o.mu.Lock()
+
+	switch o.state {
+	case StateStarting, StateRunning:
+		o.mu.Unlock()
+
+		return nil, ErrAlreadyRunning
+
+	case StateStopping:
+		o.mu.Unlock()
+
+		return nil, ErrServerClosed
+	}
+
+	prevState := o.state
+
+	o.state = StateStarting
 	o.clients = make(map[int]*Client, 0)
+	o.groups = make(map[string]map[int]*Client, 0)
+	o.subscribers = make(map[string]map[int]*Client, 0)
 	o.chStarted = make(chan bool, 1)
 	o.chKill = make(chan bool, 1)
-	o.chStopped = make(chan bool, 1)
+	o.chStopped = make(chan bool)
+	o.chShutdown = make(chan context.Context, 1)
+	o.shutdownErr = nil
 
-	//
-	// Resolve the address.
-	//
-	tcpAddr, tcpAddrErr := net.ResolveTCPAddr("tcp", o.config.Address)
-	if tcpAddrErr != nil {
-		return nil, tcpAddrErr
-	}
+	o.mu.Unlock()
 
 	//
-	// Attempt to bind to the configured ip address and port.
+	// Attempt to bind to the configured address. If the server was created with a custom listen
+	// function, it is entirely responsible for doing so.
 	//
 	var listenerErr error
 
-	if o.tlsConfig == nil {
-		o.listener, listenerErr = net.Listen("tcp", tcpAddr.String())
+	if o.listenFunc != nil {
+		o.listener, listenerErr = o.listenFunc(o.config.Address)
 	} else {
-		o.listener, listenerErr = tls.Listen("tcp", tcpAddr.String(), o.tlsConfig)
+		o.listener, listenerErr = o.listenTCP()
 	}
 
+	//
+	// NOTE: If binding failed, anyone who tried to stop the server while it was starting is told that
+	//  it has stopped.
+	//
 	if listenerErr != nil {
+		o.mu.Lock()
+		o.state = prevState
+		close(o.chStopped)
+		o.mu.Unlock()
+
 		return nil, listenerErr
 	}
 
+	o.mu.Lock()
+	o.addr = o.listener.Addr()
+	o.mu.Unlock()
+
 	//
 	// Fire up a goroutine to loop infinitely to accept new connections and spin off a handler thread
 	// for each until the kill signal is sent.
@@ -159,34 +338,61 @@ func (o *Server) Start() (<-chan bool, error) {
 }
 
 //
-// Stop implements the method described by packetsvr.Server interface.
+// Logger returns the logger that the server reports its lifecycle events to.
+//
+func (o *Server) Logger() Logger {
+	return o.logger
+}
+
+//
+// Addr implements the method described by packetsvr.Server interface. Once the server has been
+// started, the address that it is (or was most recently) bound to is returned. This is how to learn
+// which port was chosen when the configured address uses port 0.
+//
+func (o *Server) Addr() net.Addr {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.addr
+}
+
+//
+// Stop implements the method described by packetsvr.Server interface. It is safe to call more than
+// once, and every returned channel will be written to once the server has stopped. Stopping a server
+// that was never started returns ErrNotRunning.
 //
 func (o *Server) Stop() (<-chan bool, error) {
 	//
 	// Log some debug info.
 	//
-	log.Print("Attempting to stop the TCP/IP packet server...")
+	o.logger.Info("Attempting to stop the TCP/IP packet server...")
 
 	//
-	// Send the kill signal.
+	// Send the kill signal, unless the server is already stopping or stopped.
 	//
-	o.chKill <- true
+	switch err := o.beginStopping(); err {
+	case nil:
+		o.chKill <- true
+
+	case ErrNotRunning:
+		return nil, err
+	}
 
 	//
 	// Return a channel that can be blocked on if it is necessary to wait for the server to completely
 	// shutdown.
 	//
-	// NOTE: The goroutine handling the server's lifecycle will send a message on the "stopped"
-	//  channel that we return here once it has completely shut down.
+	// NOTE: The goroutine handling the server's lifecycle will close the "stopped" channel once it
+	//  has completely shut down, which in turn writes to the channel that we return here.
 	//
-	return o.chStopped, nil
+	return o.stoppedSignal(), nil
 }
 
 //
 // CreateServer creates a new regular server instance.
 //
 func CreateServer(config *ServerConfig) (*Server, error) {
-	log.Print("Creating a TCP/IP packet server with address ", config.Address, ".")
+	LoggerFor(config.Logger).Info("Creating a TCP/IP packet server.", "address", config.Address)
 
 	err := validateConfig(config)
 	if err != nil {
@@ -197,6 +403,9 @@ func CreateServer(config *ServerConfig) (*Server, error) {
 		mu:        &sync.Mutex{},
 		config:    config,
 		tlsConfig: nil,
+		framer:    framerFor(config),
+		logger:    LoggerFor(config.Logger),
+		metrics:   metricsFor(config.Metrics),
 	}
 
 	return server, nil
@@ -206,7 +415,7 @@ func CreateServer(config *ServerConfig) (*Server, error) {
 // CreateServerWithTLS creates a new TLS-enabled server instance that can handle secure connections.
 //
 func CreateServerWithTLS(config *ServerConfig, certFile string, keyFile string) (*Server, error) {
-	log.Print("Creating TLS-enabled TCP/IP packet server with address ", config.Address, ".")
+	LoggerFor(config.Logger).Info("Creating a TLS-enabled TCP/IP packet server.", "address", config.Address)
 
 	err := validateConfig(config)
 	if err != nil {
@@ -221,6 +430,36 @@ func CreateServerWithTLS(config *ServerConfig, certFile string, keyFile string)
 		mu:        &sync.Mutex{},
 		config:    config,
 		tlsConfig: &tlsConfig,
+		framer:    framerFor(config),
+		logger:    LoggerFor(config.Logger),
+		metrics:   metricsFor(config.Metrics),
+	}
+
+	return server, nil
+}
+
+//
+// CreateServerWithListener creates a new server instance that obtains its listener from the
+// provided function each time it is started rather than binding a TCP/IP listener itself. This
+// allows any transport that can be presented as a stream-oriented net.Listener to reuse the
+// server's entire client lifecycle.
+//
+func CreateServerWithListener(config *ServerConfig, listenFunc ListenFunc) (*Server, error) {
+	LoggerFor(config.Logger).Info("Creating a packet server with a custom listener.", "address", config.Address)
+
+	err := validateConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		mu:         &sync.Mutex{},
+		config:     config,
+		tlsConfig:  nil,
+		listenFunc: listenFunc,
+		framer:     framerFor(config),
+		logger:     LoggerFor(config.Logger),
+		metrics:    metricsFor(config.Metrics),
 	}
 
 	return server, nil
@@ -235,9 +474,62 @@ func validateConfig(config *ServerConfig) error {
 		return errors.New("an address ({ip}:{port}) must be specified")
 	}
 
+	if config.SendQueueSize < 0 {
+		return errors.New("the send queue size must not be negative")
+	}
+
+	if config.MaxMessageSize < 0 {
+		return errors.New("the maximum message size must not be negative")
+	}
+
+	if config.ReadIdleTimeout < 0 || config.WriteTimeout < 0 || config.HandshakeTimeout < 0 ||
+		config.HeartbeatInterval < 0 || config.HeartbeatTimeout < 0 {
+		return errors.New("timeouts must not be negative")
+	}
+
+	if framer, ok := config.Framer.(*LengthPrefixFramer); ok {
+		if err := framer.validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+//
+// framerFor returns the framer that a server created with the provided configuration should use.
+//
+func framerFor(config *ServerConfig) Framer {
+	if config.Framer != nil {
+		return config.Framer
+	}
+
+	return &DelimFramer{Delim: config.Delim}
+}
+
+//
+// listenTCP resolves the configured address and binds a TCP/IP listener (secured with TLS if the
+// server was configured to use it) to it.
+//
+func (o *Server) listenTCP() (net.Listener, error) {
+	//
+	// Resolve the address.
+	//
+	tcpAddr, tcpAddrErr := net.ResolveTCPAddr("tcp", o.config.Address)
+	if tcpAddrErr != nil {
+		return nil, tcpAddrErr
+	}
+
+	//
+	// Attempt to bind to the configured ip address and port.
+	//
+	if o.tlsConfig == nil {
+		return net.Listen("tcp", tcpAddr.String())
+	}
+
+	return tls.Listen("tcp", tcpAddr.String(), o.tlsConfig)
+}
+
 //
 // getAndIncrementNextClientID returns the next unique identifier that can be assigned to a new
 // client.
@@ -269,8 +561,8 @@ func (o *Server) addClient(client *Client, id int) {
 }
 
 //
-// forgetClient removes the specified client from the server's client table (if it exists). Note
-// that it does NOT close the connection to the client.
+// forgetClient removes the specified client from the server's client table (if it exists) and from
+// every group that it is a member of. Note that it does NOT close the connection to the client.
 //
 func (o *Server) forgetClient(c *Client) {
 	// NOTE:  We must lock because we are going to mutate the client table. Multiple goroutines may
@@ -280,6 +572,14 @@ func (o *Server) forgetClient(c *Client) {
 	defer o.mu.Unlock()
 
 	delete(o.clients, c.ID())
+
+	for group := range c.groups {
+		o.leaveGroupLocked(group, c)
+	}
+
+	for pattern := range c.subscriptions {
+		o.unsubscribeLocked(pattern, c)
+	}
 }
 
 //
@@ -289,13 +589,15 @@ func (o *Server) forgetClient(c *Client) {
 //
 func (o *Server) handleNewClient(conn net.Conn) {
 	id := o.getAndIncrementNextClientID()
-	client := CreateClient(id, conn, o, o.config.Delim)
+	client := CreateClientWithFramer(id, conn, o, o.framer)
 
 	o.addClient(client, id)
 
+	o.metrics.ClientConnected()
+
 	go client.listen()
 
-	log.Printf("%sA TCP/IP client has connected.", client.LogPrefix())
+	o.logger.Info("A TCP/IP client has connected.", client.logFields()...)
 }
 
 //
@@ -306,6 +608,7 @@ func (o *Server) listen() {
 	// Spin off a goroutine to listen for new connections.
 	//
 	chListener := make(chan net.Conn)
+	chListenerQuit := make(chan bool)
 	chListenerDone := make(chan bool, 1)
 
 	go func() {
@@ -319,24 +622,35 @@ func (o *Server) listen() {
 			conn, err := o.listener.Accept()
 			if err != nil {
 				if realErr, ok := err.(net.Error); ok && realErr.Temporary() {
-					log.Printf(
+					o.logger.Warn(
 						"A temporary error occured while listening for new TCP/IP connections. Will continue "+
-							"listening after a short delay. (Error: %s)",
-						err,
+							"listening after a short delay.",
+						"error", err,
 					)
 
+					o.metrics.AcceptFailed()
+					o.onError(nil, &OpError{Op: "accept", Err: err})
+
 					time.Sleep(1 * time.Second)
 				} else {
-					log.Printf(
-						"A critical failure occurred while listening for new TCP/IP connections. (Error: %s) "+
-							"(Hint: Was the server shut down?)",
-						err,
+					o.logger.Debug(
+						"A critical failure occurred while listening for new TCP/IP connections. Was the server "+
+							"shut down?",
+						"error", err,
 					)
 
+					if o.State() != StateStopping {
+						o.onError(nil, &OpError{Op: "accept", Err: err})
+					}
+
 					break
 				}
 			} else {
-				chListener <- conn
+				select {
+				case chListener <- conn:
+				case <-chListenerQuit:
+					conn.Close()
+				}
 			}
 		}
 
@@ -348,13 +662,21 @@ func (o *Server) listen() {
 	//
 	// Indicate that the server has started.
 	//
+	o.mu.Lock()
+	if o.state == StateStarting {
+		o.state = StateRunning
+	}
+	o.mu.Unlock()
+
 	o.chStarted <- true
 
-	log.Print("The TCP/IP packet server has been started.")
+	o.logger.Info("The TCP/IP packet server has been started.", "address", o.Addr())
 
 	//
 	// Select on either new connections or a kill signal.
 	//
+	var shutdownCtx context.Context
+
 	stop := false
 
 	for !stop {
@@ -368,36 +690,60 @@ func (o *Server) listen() {
 
 		case <-o.chKill:
 			stop = true
+
+		case shutdownCtx = <-o.chShutdown:
+			stop = true
 		}
 	}
 
+	o.setState(StateStopping)
+
 	//
 	// Close the listener and block until the listener goroutine completes.
 	//
-	log.Print("Closing the TCP/IP packet server listener...")
+	// NOTE: The listener goroutine may be blocked trying to hand us a connection that we will never
+	//  receive, so we tell it to stop trying (and to close any such connection) first.
+	//
+	o.logger.Info("Closing the TCP/IP packet server listener...")
+
+	close(chListenerQuit)
 
 	o.listener.Close()
 
 	<-chListenerDone
 
 	//
-	// Disconnect all clients and wait for them to finish cleaning themselves up.
+	// If we are gracefully shutting down, give every client a chance to drain before the deadline.
+	// Otherwise, disconnect all clients and wait for them to finish cleaning themselves up.
+	//
+	// NOTE: Any client still left after draining was forcibly disconnected once the deadline passed,
+	//  and is left to finish cleaning itself up in the background.
 	//
-	log.Printf("Disconnecting all %d clients from the TCP/IP packet server...", len(o.clients))
+	o.shutdownErr = nil
 
-	for _, e := range o.clients {
-		<-e.Close()
+	if shutdownCtx != nil {
+		o.shutdownErr = o.drain(shutdownCtx, o.Clients())
+	} else {
+		clients := o.Clients()
+
+		o.logger.Info("Disconnecting all clients from the TCP/IP packet server...", "clients", len(clients))
+
+		for _, e := range clients {
+			<-e.closeWithReason(DisconnectServerShutdown, nil)
+		}
 	}
 
 	//
 	// Log some debug info.
 	//
-	log.Print("The TCP/IP packet server has been stopped.")
+	o.logger.Info("The TCP/IP packet server has been stopped.")
 
 	//
 	// Tell anyone waiting on us that we are done.
 	//
-	o.chStopped <- true
+	o.setState(StateStopped)
+
+	close(o.chStopped)
 
 	return
 }
@@ -1,11 +1,22 @@
 package tcp
 
 import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
 	"net"
 	"testing"
 	"time"
+
+	packetsvr "github.com/lukehollenback/packet-server"
 )
 
+//
+// Make sure that the server satisfies the protocol-agnostic server interface.
+//
+var _ packetsvr.Server = (*Server)(nil)
+
 const TestServerAddress = "localhost:9999"
 const TestMessage = "This is a test message. Here is a number: 12345.67890!\x00"
 
@@ -212,3 +223,728 @@ func TestServerShutdownBeforeClientDisconnect(t *testing.T) {
 
 	<-chStopped
 }
+
+func TestLengthPrefixedBytesLifecycle(t *testing.T) {
+	chMessageBytes := make(chan []byte, 1)
+
+	pyld := []byte("binary\n\x00payload")
+	framer := &LengthPrefixFramer{Width: 2}
+
+	//
+	// Create a new server that splits messages using length headers rather than a delimiter.
+	//
+	server, err := CreateServer(&ServerConfig{
+		Address:           TestServerAddress,
+		Framer:            framer,
+		OnNewMessageBytes: func(c *Client, b []byte) { chMessageBytes <- b },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	server.Start()
+
+	//
+	// Give the server some time to bind.
+	//
+	time.Sleep(10 * time.Millisecond)
+
+	//
+	// Connect to the server as a new client and sent it a framed test message.
+	//
+	conn, err := net.Dial("tcp", TestServerAddress)
+
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	frame, _ := framer.EncodeFrame(pyld)
+
+	conn.Write(frame)
+
+	conn.Close()
+
+	//
+	// Assert that the raw payload came through without its length header.
+	//
+	select {
+	case messageBytes := <-chMessageBytes:
+		if !bytes.Equal(messageBytes, pyld) {
+			t.Errorf("A message was recieved, but it was not equal to what was expected. (Got: %q)", messageBytes)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("The \"OnNewMessageBytes\" event handler never fired.")
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestOversizePolicies(t *testing.T) {
+	for _, policy := range []OversizePolicy{OversizeDisconnect, OversizeDiscard, OversizeCallback} {
+		//
+		// Create a new server with a small maximum message size that reports back everything that
+		// happens to its clients.
+		//
+		chMessage := make(chan string, 2)
+		chOversized := make(chan bool, 1)
+		chDisconnected := make(chan DisconnectReason, 1)
+
+		server, err := CreateServer(&ServerConfig{
+			Address:              "127.0.0.1:0",
+			Delim:                '\n',
+			MaxMessageSize:       8,
+			OversizePolicy:       policy,
+			OnNewMessage:         func(c *Client, message string) { chMessage <- message },
+			OnOversizedMessage:   func(c *Client) { chOversized <- true },
+			OnClientDisconnected: func(c *Client, reason DisconnectReason) { chDisconnected <- reason },
+		})
+		if err != nil {
+			t.Fatalf("The server failed to create. (Error: %s)", err)
+		}
+
+		chStarted, _ := server.Start()
+
+		<-chStarted
+
+		conn, err := net.Dial("tcp", server.Addr().String())
+		if err != nil {
+			t.Fatal("Failed to connect to the test server.")
+		}
+
+		//
+		// Send an oversized message sandwiched between two that are exactly the maximum size.
+		//
+		conn.Write([]byte("12345678\n123456789\nabcdefgh\n"))
+
+		select {
+		case message := <-chMessage:
+			if message != "12345678\n" {
+				t.Errorf("A message was recieved, but it was not equal to what was expected. (Policy: %d) (Got: %q)", policy, message)
+			}
+		case <-time.After(1 * time.Second):
+			t.Errorf("A message of exactly the maximum size never arrived. (Policy: %d)", policy)
+		}
+
+		if policy == OversizeDisconnect {
+			select {
+			case reason := <-chDisconnected:
+				if reason != DisconnectOversizedMessage {
+					t.Errorf("A client was disconnected for the wrong reason. (Reason: %s)", reason)
+				}
+			case <-time.After(1 * time.Second):
+				t.Error("A client that sent an oversized message was never disconnected.")
+			}
+		} else {
+			select {
+			case message := <-chMessage:
+				if message != "abcdefgh\n" {
+					t.Errorf("The message after an oversized one was not recieved. (Policy: %d) (Got: %q)", policy, message)
+				}
+			case <-time.After(1 * time.Second):
+				t.Errorf("The message after an oversized one never arrived. (Policy: %d)", policy)
+			}
+		}
+
+		select {
+		case <-chOversized:
+			if policy != OversizeCallback {
+				t.Errorf("The \"OnOversizedMessage\" event handler fired unexpectedly. (Policy: %d)", policy)
+			}
+		default:
+			if policy == OversizeCallback {
+				t.Error("The \"OnOversizedMessage\" event handler never fired.")
+			}
+		}
+
+		conn.Close()
+
+		chStopped, _ := server.Stop()
+
+		<-chStopped
+	}
+}
+
+func TestSlowConsumerDisconnect(t *testing.T) {
+	//
+	// Create a new server that floods each new client with large messages, disconnecting clients
+	// that cannot keep up.
+	//
+	chSendErr := make(chan error, 1)
+	chSlowConsumer := make(chan bool, 1)
+	chConnectionClosed := make(chan bool, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:            TestServerAddress,
+		Delim:              '\x00',
+		SendQueueSize:      1,
+		SlowConsumerPolicy: SlowConsumerDisconnect,
+		OnNewClient: func(c *Client) {
+			go func() {
+				pyld := make([]byte, 1<<20)
+
+				for i := 0; i < 1000; i++ {
+					if err := c.SendBytes(pyld); err != nil {
+						chSendErr <- err
+
+						return
+					}
+				}
+
+				chSendErr <- nil
+			}()
+		},
+		OnSlowConsumer:           func(c *Client) { chSlowConsumer <- true },
+		OnClientConnectionClosed: func(c *Client) { chConnectionClosed <- true },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Connect to the server as a new client, but never read anything.
+	//
+	conn, err := net.Dial("tcp", TestServerAddress)
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	//
+	// Assert that the client was deemed a slow consumer and disconnected.
+	//
+	select {
+	case err := <-chSendErr:
+		if err != ErrSendQueueFull && err != ErrClientClosed {
+			t.Errorf("Flooding a client that never reads did not fail as expected. (Error: %v)", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flooding a client that never reads never failed.")
+	}
+
+	select {
+	case <-chSlowConsumer:
+	case <-time.After(1 * time.Second):
+		t.Error("The \"OnSlowConsumer\" event handler never fired.")
+	}
+
+	select {
+	case <-chConnectionClosed:
+	case <-time.After(1 * time.Second):
+		t.Error("The slow consumer was never disconnected.")
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestSendThenClose(t *testing.T) {
+	//
+	// Create a new server that says goodbye to and then immediately closes each new client.
+	//
+	server, err := CreateServer(&ServerConfig{
+		Address: TestServerAddress,
+		Delim:   '\x00',
+		OnNewClient: func(c *Client) {
+			c.Send("bye")
+			c.Close()
+		},
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Connect to the server several times and assert that the goodbye message always makes it out
+	// before the connection is closed.
+	//
+	for i := 0; i < 20; i++ {
+		conn, err := net.Dial("tcp", TestServerAddress)
+		if err != nil {
+			t.Fatal("Failed to connect to the test server.")
+		}
+
+		conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+		buf, err := ioutil.ReadAll(conn)
+		if string(buf) != "bye\x00" {
+			t.Errorf("A message sent right before closing was not delivered. (Got: %q) (Error: %v)", buf, err)
+		}
+
+		conn.Close()
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestSendAll(t *testing.T) {
+	//
+	// Create a new server that tells us whenever a client connects.
+	//
+	chNewClient := make(chan bool, 3)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:     TestServerAddress,
+		Delim:       '\x00',
+		OnNewClient: func(c *Client) { chNewClient <- true },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Connect several clients to the server and wait for it to see all of them.
+	//
+	var conns []net.Conn
+
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("tcp", TestServerAddress)
+		if err != nil {
+			t.Fatal("Failed to connect to the test server.")
+		}
+
+		defer conn.Close()
+
+		conns = append(conns, conn)
+
+		<-chNewClient
+	}
+
+	//
+	// Broadcast a message and assert that every client receives it.
+	//
+	if err := server.SendAll("hello"); err != nil {
+		t.Fatalf("The broadcast failed. (Error: %s)", err)
+	}
+
+	for i, conn := range conns {
+		buf := make([]byte, 6)
+
+		conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+		if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello\x00" {
+			t.Errorf("Client %d did not receive the broadcast. (Got: %q) (Error: %v)", i, buf, err)
+		}
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestClientLookupAndKick(t *testing.T) {
+	//
+	// Create a new server that tells us whenever a client connects or disconnects.
+	//
+	chNewClient := make(chan *Client, 2)
+	chConnectionClosed := make(chan *Client, 2)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:                  TestServerAddress,
+		Delim:                    '\x00',
+		OnNewClient:              func(c *Client) { chNewClient <- c },
+		OnClientConnectionClosed: func(c *Client) { chConnectionClosed <- c },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Connect two clients and assert that the server can enumerate and find both of them.
+	//
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", TestServerAddress)
+		if err != nil {
+			t.Fatal("Failed to connect to the test server.")
+		}
+
+		defer conn.Close()
+	}
+
+	first, second := <-chNewClient, <-chNewClient
+
+	if count := server.ClientCount(); count != 2 {
+		t.Errorf("The server reported %d clients instead of 2.", count)
+	}
+
+	if server.Client(first.ID()) != first || server.Client(second.ID()) != second {
+		t.Error("The server could not find a connected client by its id.")
+	}
+
+	visited := 0
+
+	server.ForEachClient(func(c *Client) bool {
+		visited++
+
+		return false
+	})
+
+	if visited != 1 {
+		t.Errorf("Enumeration did not stop when asked to. (Visited: %d)", visited)
+	}
+
+	//
+	// Kick one of the clients and assert that it is gone.
+	//
+	chKicked, err := server.Kick(first.ID(), "testing")
+	if err != nil {
+		t.Fatalf("Failed to kick a connected client. (Error: %s)", err)
+	}
+
+	<-chKicked
+
+	if closed := <-chConnectionClosed; closed != first {
+		t.Errorf("A client other than the kicked one was disconnected. (Client: %s)", closed)
+	}
+
+	if clients := server.Clients(); len(clients) != 1 || clients[0] != second {
+		t.Errorf("The kicked client is still in the client table. (Clients: %v)", clients)
+	}
+
+	if _, err := server.Kick(first.ID(), "testing"); err != ErrClientNotFound {
+		t.Errorf("Kicking a disconnected client did not fail as expected. (Error: %v)", err)
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestDisconnectReasons(t *testing.T) {
+	//
+	// Create a new server that tells us whenever a client connects or disconnects, and why.
+	//
+	chNewClient := make(chan *Client, 3)
+	chDisconnected := make(chan DisconnectReason, 3)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:              TestServerAddress,
+		Delim:                '\x00',
+		OnNewClient:          func(c *Client) { chNewClient <- c },
+		OnClientDisconnected: func(c *Client, reason DisconnectReason) { chDisconnected <- reason },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Connect three clients. One will be kicked, one will hang up, and one will still be connected
+	// when the server shuts down.
+	//
+	conns := make([]net.Conn, 3)
+	clients := make([]*Client, 3)
+
+	for i := range conns {
+		conn, err := net.Dial("tcp", TestServerAddress)
+		if err != nil {
+			t.Fatal("Failed to connect to the test server.")
+		}
+
+		defer conn.Close()
+
+		conns[i] = conn
+		clients[i] = <-chNewClient
+	}
+
+	if reason := clients[0].DisconnectReason(); reason != DisconnectNone {
+		t.Errorf("A connected client reported a disconnect reason. (Reason: %s)", reason)
+	}
+
+	chKicked, _ := server.Kick(clients[0].ID(), "testing")
+
+	<-chKicked
+
+	if reason := <-chDisconnected; reason != DisconnectKicked {
+		t.Errorf("A kicked client reported the wrong disconnect reason. (Reason: %s)", reason)
+	}
+
+	if err := clients[0].DisconnectErr(); err == nil || err.Error() != "testing" {
+		t.Errorf("A kicked client did not report why it was kicked. (Error: %v)", err)
+	}
+
+	conns[1].Close()
+
+	if reason := <-chDisconnected; reason != DisconnectPeerClosed {
+		t.Errorf("A client that hung up reported the wrong disconnect reason. (Reason: %s)", reason)
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+
+	if reason := <-chDisconnected; reason != DisconnectServerShutdown {
+		t.Errorf("A client disconnected by shutdown reported the wrong reason. (Reason: %s)", reason)
+	}
+
+	if reason := clients[2].DisconnectReason(); reason != DisconnectServerShutdown {
+		t.Errorf("A client did not remember why it was disconnected. (Reason: %s)", reason)
+	}
+}
+
+func TestReadIdleTimeout(t *testing.T) {
+	//
+	// Create a new server that disconnects clients that go quiet, and tells us why they were
+	// disconnected.
+	//
+	chDisconnected := make(chan DisconnectReason, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:              TestServerAddress,
+		Delim:                '\x00',
+		ReadIdleTimeout:      100 * time.Millisecond,
+		OnClientDisconnected: func(c *Client, reason DisconnectReason) { chDisconnected <- reason },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Connect and dribble out part of a message, slowloris-style, and assert that the client is
+	// disconnected anyway.
+	//
+	conn, err := net.Dial("tcp", TestServerAddress)
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		conn.Write([]byte("x"))
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	select {
+	case reason := <-chDisconnected:
+		if reason != DisconnectIdleTimeout {
+			t.Errorf("An idle client was disconnected for the wrong reason. (Reason: %s)", reason)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("An idle client was never disconnected.")
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestWriteTimeout(t *testing.T) {
+	//
+	// Create a new server that gives up on writes that take too long, and tells us about its clients
+	// and why they were disconnected.
+	//
+	chClient := make(chan *Client, 1)
+	chDisconnected := make(chan DisconnectReason, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:              "127.0.0.1:0",
+		Delim:                '\x00',
+		WriteTimeout:         100 * time.Millisecond,
+		OnNewClient:          func(c *Client) { chClient <- c },
+		OnClientDisconnected: func(c *Client, reason DisconnectReason) { chDisconnected <- reason },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Connect but never read, and keep sending until the socket buffers fill up and a write blocks.
+	//
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	client := <-chClient
+
+	go func() {
+		payload := bytes.Repeat([]byte("x"), 1<<20)
+
+		for client.SendBytes(payload) == nil {
+		}
+	}()
+
+	select {
+	case reason := <-chDisconnected:
+		if reason != DisconnectWriteTimeout {
+			t.Errorf("A client that never read was disconnected for the wrong reason. (Reason: %s)", reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("A client that never read was never disconnected.")
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+//
+// disconnectMetrics is a Metrics sink that reports why each client was disconnected.
+//
+type disconnectMetrics struct {
+	nopMetrics
+
+	chDisconnected chan DisconnectReason // Channel that each disconnect reason is written to.
+}
+
+//
+// ClientDisconnected implements the method described by the Metrics interface.
+//
+func (o *disconnectMetrics) ClientDisconnected(reason DisconnectReason) {
+	o.chDisconnected <- reason
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	//
+	// Create a new TLS server that gives up on handshakes that take too long. Clients that fail
+	// their handshake are never reported to the event handlers, so listen to the metrics instead.
+	//
+	// NOTE: The handshake never gets far enough for a certificate to be needed.
+	//
+	metrics := &disconnectMetrics{chDisconnected: make(chan DisconnectReason, 1)}
+
+	server, err := CreateServerWithListener(&ServerConfig{
+		Address:          "127.0.0.1:0",
+		Delim:            '\x00',
+		HandshakeTimeout: 100 * time.Millisecond,
+		Metrics:          metrics,
+	}, func(address string) (net.Listener, error) {
+		ln, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+
+		return tls.NewListener(ln, &tls.Config{}), nil
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	//
+	// Connect with plain TCP/IP so that the handshake is never started, and assert that the client is
+	// disconnected anyway.
+	//
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	select {
+	case reason := <-metrics.chDisconnected:
+		if reason != DisconnectHandshakeFailed {
+			t.Errorf("A client that never completed its handshake was disconnected for the wrong reason. (Reason: %s)", reason)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("A client that never completed its handshake was never disconnected.")
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestAddrWithEphemeralPort(t *testing.T) {
+	server, err := CreateServer(&ServerConfig{Address: "localhost:0", Delim: '\x00'})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	if addr := server.Addr(); addr != nil {
+		t.Errorf("A server that was never started reported an address. (Address: %s)", addr)
+	}
+
+	chStarted, err := server.Start()
+	if err != nil {
+		t.Fatalf("The server failed to start. (Error: %s)", err)
+	}
+
+	<-chStarted
+
+	//
+	// Assert that the port that was actually chosen is reported, and that it can be dialed.
+	//
+	addr, ok := server.Addr().(*net.TCPAddr)
+	if !ok || addr.Port == 0 {
+		t.Fatalf("The server did not report the address it is bound to. (Address: %v)", server.Addr())
+	}
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("Failed to connect to the reported address. (Error: %s)", err)
+	}
+
+	conn.Close()
+
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
@@ -0,0 +1,160 @@
+package tcp
+
+import (
+	"context"
+	"fmt"
+)
+
+//
+// ShutdownError describes what was cut off when a graceful shutdown's deadline passed before every
+// client had finished draining.
+//
+type ShutdownError struct {
+	Clients  int   // The number of clients that had to be forcibly disconnected.
+	Messages int   // The number of outbound messages that were still queued for those clients.
+	Err      error // Why the deadline passed (i.e. the error of the shutdown's context).
+}
+
+//
+// Error implements the method described by the error interface.
+//
+func (o *ShutdownError) Error() string {
+	return fmt.Sprintf(
+		"shutdown cut off: %d clients were forcibly disconnected with %d outbound messages still queued (%s)",
+		o.Clients,
+		o.Messages,
+		o.Err,
+	)
+}
+
+//
+// Unwrap returns the error of the shutdown's context so that errors.Is(err,
+// context.DeadlineExceeded) works as expected.
+//
+func (o *ShutdownError) Unwrap() error {
+	return o.Err
+}
+
+//
+// Shutdown gracefully shuts the server down. The server immediately stops accepting new
+// connections and sends the configured goodbye message (if any) to every client. Each client then
+// finishes handling whatever message it is in the middle of, stops handling new ones, and has its
+// outbound queue flushed before being disconnected. Any clients that have not finished by the time
+// the provided context is done are forcibly disconnected, in which case a *ShutdownError describing
+// what was cut off is returned. Shutdown blocks until the server has completely stopped, but it does
+// not wait for forcibly disconnected clients whose handlers are stuck; they finish (and are reported
+// to the registered event handlers) in the background.
+//
+// Shutting down a server that was never started returns ErrNotRunning, and shutting down one that is
+// already stopping or stopped returns ErrServerClosed.
+//
+func (o *Server) Shutdown(ctx context.Context) error {
+	o.logger.Info("Attempting to gracefully shut down the TCP/IP packet server...")
+
+	if err := o.beginStopping(); err != nil {
+		return err
+	}
+
+	o.chShutdown <- ctx
+
+	<-o.chStopped
+
+	return o.shutdownErr
+}
+
+//
+// drain gracefully disconnects the provided clients, forcibly disconnecting any that have not
+// finished by the time the provided context is done. It does not wait for forcibly disconnected
+// clients to finish, as their handlers may never return.
+//
+func (o *Server) drain(ctx context.Context, clients []*Client) error {
+	o.logger.Info("Draining all clients of the TCP/IP packet server...", "clients", len(clients))
+
+	if len(o.config.GoodbyeMessage) > 0 {
+		if frame, err := o.framer.EncodeFrame(o.config.GoodbyeMessage); err != nil {
+			o.logger.Error("Failed to encode the goodbye message.", "error", err)
+		} else {
+			for _, e := range clients {
+				e.trySendFrame(frame)
+			}
+		}
+	}
+
+	for _, e := range clients {
+		e.drain()
+	}
+
+	//
+	// Wait for each client to finish, forcibly disconnecting whatever is left once the deadline
+	// passes.
+	//
+	var cutOff *ShutdownError
+
+	for _, e := range clients {
+		select {
+		case <-e.chDone:
+			continue
+
+		case <-ctx.Done():
+		}
+
+		if cutOff == nil {
+			cutOff = &ShutdownError{Err: ctx.Err()}
+		}
+
+		cutOff.Clients++
+		cutOff.Messages += e.QueueLen()
+
+		o.logger.Warn(
+			"Forcibly disconnecting the TCP/IP client because the shutdown deadline passed.",
+			e.logFields("queued", e.QueueLen())...,
+		)
+
+		e.closeWithReason(DisconnectServerShutdown, ctx.Err())
+		e.conn.Close()
+	}
+
+	if cutOff != nil {
+		return cutOff
+	}
+
+	return nil
+}
+
+//
+// drain tells the client to stop handling new messages, flush its outbound queue, and then
+// disconnect. It does not wait for the client to do so. It is safe to call more than once.
+//
+func (o *Client) drain() {
+	o.drainOnce.Do(func() {
+		close(o.chDrain)
+	})
+}
+
+//
+// flush blocks until everything that was queued for the client before it was called has been
+// written (or dropped by the slow consumer policy), or until the client is stopped.
+//
+func (o *Client) flush() {
+	select {
+	case o.chFlush <- true:
+	case <-o.chStop:
+		return
+	}
+
+	select {
+	case <-o.chFlushed:
+	case <-o.chStop:
+	}
+}
+
+//
+// trySendFrame queues the provided, already encoded frame for the client without blocking, quietly
+// giving up if the client's outbound queue is full.
+//
+func (o *Client) trySendFrame(frame []byte) {
+	select {
+	case o.chSend <- frame:
+	default:
+	}
+}
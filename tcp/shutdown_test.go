@@ -0,0 +1,263 @@
+package tcp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsClients(t *testing.T) {
+	//
+	// Create a new server that takes a while to answer each message.
+	//
+	chHandling := make(chan bool, 1)
+	chDisconnected := make(chan DisconnectReason, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:        "127.0.0.1:0",
+		Delim:          '\n',
+		GoodbyeMessage: []byte("goodbye"),
+		OnNewMessage: func(c *Client, msg string) {
+			chHandling <- true
+
+			time.Sleep(100 * time.Millisecond)
+
+			c.Send("reply\n")
+		},
+		OnClientDisconnected: func(c *Client, reason DisconnectReason) { chDisconnected <- reason },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	//
+	// Shut the server down while a message is being handled, and assert that the handler's reply
+	// still makes it out before the client is disconnected.
+	//
+	conn.Write([]byte("hello\n"))
+
+	<-chHandling
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("A graceful shutdown reported that it cut something off. (Error: %s)", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	reader := bufio.NewReader(conn)
+
+	for _, expected := range []string{"goodbye\n", "reply\n"} {
+		if line, err := reader.ReadString('\n'); line != expected {
+			t.Errorf("Expected %q to be flushed before disconnecting. (Got: %q) (Error: %v)", expected, line, err)
+		}
+	}
+
+	if reason := <-chDisconnected; reason != DisconnectServerShutdown {
+		t.Errorf("A drained client was disconnected for the wrong reason. (Reason: %s)", reason)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	//
+	// Create a new server with a handler that takes longer than we are willing to wait.
+	//
+	chHandling := make(chan bool, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address: "127.0.0.1:0",
+		Delim:   '\n',
+		OnNewMessage: func(c *Client, msg string) {
+			chHandling <- true
+
+			time.Sleep(200 * time.Millisecond)
+		},
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	conn.Write([]byte("hello\n"))
+
+	<-chHandling
+
+	//
+	// Assert that the shutdown reports the client that it had to cut off.
+	//
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err = server.Shutdown(ctx)
+
+	var shutdownErr *ShutdownError
+
+	if !errors.As(err, &shutdownErr) || shutdownErr.Clients != 1 {
+		t.Errorf("A shutdown that missed its deadline did not report what it cut off. (Error: %v)", err)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("A shutdown that missed its deadline did not report why. (Error: %v)", err)
+	}
+}
+
+func TestShutdownDoesNotWaitForStuckClients(t *testing.T) {
+	//
+	// Create a new server with a handler that does not return until the test is over.
+	//
+	chHandling := make(chan bool, 1)
+	chRelease := make(chan bool)
+	chDisconnected := make(chan DisconnectReason, 1)
+
+	defer func() {
+		close(chRelease)
+
+		select {
+		case <-chDisconnected:
+		case <-time.After(1 * time.Second):
+			t.Error("The stuck client never finished disconnecting in the background.")
+		}
+	}()
+
+	server, err := CreateServer(&ServerConfig{
+		Address: "127.0.0.1:0",
+		Delim:   '\n',
+		OnNewMessage: func(c *Client, msg string) {
+			chHandling <- true
+
+			<-chRelease
+		},
+		OnClientDisconnected: func(c *Client, reason DisconnectReason) { chDisconnected <- reason },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	conn.Write([]byte("hello\n"))
+
+	<-chHandling
+
+	//
+	// Assert that the shutdown returns shortly after its deadline even though the client's handler
+	// is still stuck.
+	//
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	chErr := make(chan error, 1)
+
+	go func() { chErr <- server.Shutdown(ctx) }()
+
+	select {
+	case err := <-chErr:
+		var shutdownErr *ShutdownError
+
+		if !errors.As(err, &shutdownErr) || shutdownErr.Clients != 1 {
+			t.Errorf("A shutdown that missed its deadline did not report what it cut off. (Error: %v)", err)
+		}
+
+	case <-time.After(1 * time.Second):
+		t.Fatal("The shutdown waited on a client whose handler was stuck.")
+	}
+}
+
+func TestFlushSurvivesDropOldest(t *testing.T) {
+	server, err := CreateServer(&ServerConfig{
+		Address:            "127.0.0.1:0",
+		Delim:              '\n',
+		SendQueueSize:      1,
+		SlowConsumerPolicy: SlowConsumerDropOldest,
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	//
+	// Create a client whose writer is stuck writing to a peer that is not reading yet, and fill its
+	// queue.
+	//
+	local, remote := net.Pipe()
+
+	defer local.Close()
+	defer remote.Close()
+
+	client := CreateClientWithFramer(0, local, server, server.framer)
+
+	chWriterDone := make(chan bool)
+
+	go client.write(chWriterDone)
+
+	client.Send("first")
+	client.Send("second")
+
+	//
+	// Ask for a flush, and then send enough to make the slow consumer policy drop the oldest queued
+	// messages out from under it.
+	//
+	chFlushed := make(chan bool, 1)
+
+	go func() {
+		client.flush()
+
+		chFlushed <- true
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	client.Send("third")
+	client.Send("fourth")
+
+	//
+	// Start reading, and assert that the flush still completes.
+	//
+	go io.Copy(ioutil.Discard, remote)
+
+	select {
+	case <-chFlushed:
+	case <-time.After(1 * time.Second):
+		t.Error("A flush never completed after the slow consumer policy dropped queued messages.")
+	}
+
+	client.stop()
+
+	<-chWriterDone
+}
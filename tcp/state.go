@@ -0,0 +1,109 @@
+package tcp
+
+import (
+	"errors"
+)
+
+//
+// Errors returned when a server's lifecycle methods are called out of order.
+//
+var (
+	ErrAlreadyRunning = errors.New("the server is already running")
+	ErrNotRunning     = errors.New("the server is not running")
+	ErrServerClosed   = errors.New("the server is shutting down or has shut down")
+)
+
+//
+// State describes where a server is in its lifecycle.
+//
+type State int
+
+const (
+	StateCreated  State = iota // The server has been created, but has never been started.
+	StateStarting              // The server is binding its listener and starting up.
+	StateRunning               // The server is accepting connections.
+	StateStopping              // The server has stopped accepting connections and is disconnecting its clients.
+	StateStopped               // The server has completely stopped. It may be started again.
+)
+
+//
+// String returns a printable representation of the state.
+//
+func (o State) String() string {
+	switch o {
+	case StateCreated:
+		return "created"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	}
+
+	return "unknown"
+}
+
+//
+// State returns where the server currently is in its lifecycle.
+//
+func (o *Server) State() State {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.state
+}
+
+//
+// setState moves the server into the provided state.
+//
+func (o *Server) setState(state State) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.state = state
+}
+
+//
+// beginStopping moves a starting or running server into the stopping state. If the server is
+// already stopping or stopped, it is left alone and ErrServerClosed is returned. If it was never
+// started, ErrNotRunning is returned.
+//
+func (o *Server) beginStopping() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	switch o.state {
+	case StateStarting, StateRunning:
+		o.state = StateStopping
+
+		return nil
+
+	case StateStopping, StateStopped:
+		return ErrServerClosed
+	}
+
+	return ErrNotRunning
+}
+
+//
+// stoppedSignal returns a channel that a "true" value will be written to once the server has
+// completely stopped. Each caller gets its own channel so that any number of them may wait.
+//
+func (o *Server) stoppedSignal() <-chan bool {
+	o.mu.Lock()
+	chStopped := o.chStopped
+	o.mu.Unlock()
+
+	ch := make(chan bool, 1)
+
+	go func() {
+		<-chStopped
+
+		ch <- true
+	}()
+
+	return ch
+}
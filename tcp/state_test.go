@@ -0,0 +1,72 @@
+package tcp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestServerStateMachine(t *testing.T) {
+	server, err := CreateServer(&ServerConfig{Address: "127.0.0.1:0", Delim: '\x00'})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	//
+	// Assert that a server that was never started cannot be stopped.
+	//
+	if state := server.State(); state != StateCreated {
+		t.Errorf("A new server is in the wrong state. (State: %s)", state)
+	}
+
+	if _, err := server.Stop(); err != ErrNotRunning {
+		t.Errorf("Stopping a server that was never started did not fail as expected. (Error: %v)", err)
+	}
+
+	//
+	// Start the server twice (the second time after it has stopped), asserting that it cannot be
+	// started while running and that it can be stopped any number of times.
+	//
+	for i := 0; i < 2; i++ {
+		chStarted, err := server.Start()
+		if err != nil {
+			t.Fatalf("The server failed to start. (Error: %s)", err)
+		}
+
+		<-chStarted
+
+		if state := server.State(); state != StateRunning {
+			t.Errorf("A started server is in the wrong state. (State: %s)", state)
+		}
+
+		if _, err := server.Start(); err != ErrAlreadyRunning {
+			t.Errorf("Starting a running server did not fail as expected. (Error: %v)", err)
+		}
+
+		chStopped, err := server.Stop()
+		if err != nil {
+			t.Fatalf("The server failed to stop. (Error: %s)", err)
+		}
+
+		chStoppedAgain, err := server.Stop()
+		if err != nil {
+			t.Fatalf("Stopping a stopping server failed. (Error: %s)", err)
+		}
+
+		for _, ch := range []<-chan bool{chStopped, chStoppedAgain} {
+			select {
+			case <-ch:
+			case <-time.After(1 * time.Second):
+				t.Fatal("The server never reported that it had stopped.")
+			}
+		}
+
+		if state := server.State(); state != StateStopped {
+			t.Errorf("A stopped server is in the wrong state. (State: %s)", state)
+		}
+	}
+
+	if err := server.Shutdown(context.Background()); err != ErrServerClosed {
+		t.Errorf("Shutting down a stopped server did not fail as expected. (Error: %v)", err)
+	}
+}
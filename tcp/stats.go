@@ -0,0 +1,73 @@
+package tcp
+
+import (
+	"time"
+)
+
+//
+// ClientStats is a snapshot of a single client's traffic statistics.
+//
+type ClientStats struct {
+	ConnectedAt      time.Time // When the client connected.
+	LastRead         time.Time // When a message was last received from the client, or zero if none has been.
+	LastWrite        time.Time // When a message was last written to the client, or zero if none has been.
+	MessagesReceived uint64    // The number of messages received from the client.
+	BytesReceived    uint64    // The number of bytes received from the client, including framing (e.g. delimiters or length headers).
+	MessagesSent     uint64    // The number of messages written to the client.
+	BytesSent        uint64    // The number of bytes written to the client, including framing (e.g. delimiters or length headers).
+	SendFailures     uint64    // The number of messages that could not be queued for or written to the client.
+	QueueLen         int       // The number of messages currently queued to be written to the client.
+}
+
+//
+// Stats returns a snapshot of the client's traffic statistics.
+//
+func (o *Client) Stats() ClientStats {
+	o.mu.Lock()
+	stats := o.stats
+	o.mu.Unlock()
+
+	stats.QueueLen = o.QueueLen()
+
+	return stats
+}
+
+//
+// recordReceived notes that a message of the provided size was received from the client.
+//
+func (o *Client) recordReceived(size int) {
+	now := time.Now()
+
+	o.mu.Lock()
+	o.lastSeen = now
+	o.stats.LastRead = now
+	o.stats.MessagesReceived++
+	o.stats.BytesReceived += uint64(size)
+	o.mu.Unlock()
+
+	o.server.metrics.MessageReceived(size)
+}
+
+//
+// recordSent notes that a message of the provided size was written to the client.
+//
+func (o *Client) recordSent(size int) {
+	o.mu.Lock()
+	o.stats.LastWrite = time.Now()
+	o.stats.MessagesSent++
+	o.stats.BytesSent += uint64(size)
+	o.mu.Unlock()
+
+	o.server.metrics.MessageSent(size)
+}
+
+//
+// recordSendFailure notes that a message could not be queued for or written to the client.
+//
+func (o *Client) recordSendFailure() {
+	o.mu.Lock()
+	o.stats.SendFailures++
+	o.mu.Unlock()
+
+	o.server.metrics.SendFailed()
+}
@@ -0,0 +1,127 @@
+package tcp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClientStats(t *testing.T) {
+	//
+	// Create a new server that echoes every message it receives.
+	//
+	chNewClient := make(chan *Client, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:           "127.0.0.1:0",
+		Delim:             '\n',
+		OnNewClient:       func(c *Client) { chNewClient <- c },
+		OnNewMessageBytes: func(c *Client, pyld []byte) { c.SendBytes(pyld) },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	client := <-chNewClient
+
+	//
+	// Send two messages and wait for both echoes.
+	//
+	conn.Write([]byte("one\ntwo\n"))
+
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	reader := bufio.NewReader(conn)
+	reader.ReadString('\n')
+	reader.ReadString('\n')
+
+	time.Sleep(10 * time.Millisecond)
+
+	//
+	// Assert that the traffic was counted.
+	//
+	stats := client.Stats()
+
+	if stats.MessagesReceived != 2 || stats.BytesReceived != 8 {
+		t.Errorf("Inbound traffic was miscounted. (Messages: %d) (Bytes: %d)", stats.MessagesReceived, stats.BytesReceived)
+	}
+
+	if stats.MessagesSent != 2 || stats.BytesSent != 8 {
+		t.Errorf("Outbound traffic was miscounted. (Messages: %d) (Bytes: %d)", stats.MessagesSent, stats.BytesSent)
+	}
+
+	if stats.ConnectedAt.IsZero() || stats.LastRead.Before(stats.ConnectedAt) || stats.LastWrite.Before(stats.LastRead) {
+		t.Errorf("The traffic timestamps are out of order. (Stats: %+v)", stats)
+	}
+
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestClientStatsCountLengthHeaders(t *testing.T) {
+	//
+	// Create a new length-prefixed server that echoes every message it receives.
+	//
+	chNewClient := make(chan *Client, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		Address:           "127.0.0.1:0",
+		Framer:            &LengthPrefixFramer{Width: 2},
+		OnNewClient:       func(c *Client) { chNewClient <- c },
+		OnNewMessageBytes: func(c *Client, pyld []byte) { c.SendBytes(pyld) },
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, _ := server.Start()
+
+	<-chStarted
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	client := <-chNewClient
+
+	//
+	// Send a message and wait for its echo.
+	//
+	conn.Write([]byte("\x00\x03one"))
+
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	io.ReadFull(conn, make([]byte, 5))
+
+	time.Sleep(10 * time.Millisecond)
+
+	//
+	// Assert that the length headers were counted the same way in both directions.
+	//
+	stats := client.Stats()
+
+	if stats.BytesReceived != 5 || stats.BytesSent != 5 {
+		t.Errorf("Length headers were miscounted. (Received: %d) (Sent: %d)", stats.BytesReceived, stats.BytesSent)
+	}
+
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
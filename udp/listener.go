@@ -0,0 +1,517 @@
+package udp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+//
+// maxDatagramSize is the largest payload that a single UDP datagram can carry.
+//
+const maxDatagramSize = 65535
+
+//
+// peerQueueSize is the number of datagrams that may be queued for a single peer before new ones
+// are dropped.
+//
+const peerQueueSize = 64
+
+//
+// errListenerClosed is returned by a listener's Accept method once it has been closed.
+//
+var errListenerClosed = errors.New("the UDP listener has been closed")
+
+//
+// listener presents a single UDP socket as a stream-oriented net.Listener. Each previously unseen
+// remote address is "accepted" as a new pseudo-connection.
+//
+// NOTE: Just like accepted TCP/IP connections outlive the listener that accepted them, closing the
+//  listener only stops new peers from being accepted. The underlying socket is shared by every peer,
+//  so it is kept open until the last of them has been closed as well.
+//
+type listener struct {
+	mu           *sync.Mutex          // Synchronizes access to the peer table and closed flag.
+	conn         net.PacketConn       // The actual UDP socket.
+	idleTimeout  time.Duration        // How long a peer may go without sending a datagram before it is closed.
+	maxPeers     int                  // The maximum number of peers that may be known at once.
+	peers        map[string]*peerConn // Holds each known peer keyed by its remote address.
+	closed       bool                 // Whether or not the listener has been closed.
+	chAccept     chan *peerConn       // Channel used to hand newly seen peers to Accept.
+	chClosed     chan bool            // Channel that is closed once the listener has been closed.
+	closeOnce    *sync.Once           // Ensures that the listener is only closed once.
+	chSockClosed chan bool            // Channel that is closed once the underlying socket has been closed.
+	sockOnce     *sync.Once           // Ensures that the underlying socket is only closed once.
+}
+
+//
+// listen binds a UDP socket to the provided address and begins reading datagrams from it.
+//
+func listen(address string, idleTimeout time.Duration, maxPeers int) (net.Listener, error) {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &listener{
+		mu:           &sync.Mutex{},
+		conn:         conn,
+		idleTimeout:  idleTimeout,
+		maxPeers:     maxPeers,
+		peers:        make(map[string]*peerConn),
+		chAccept:     make(chan *peerConn),
+		chClosed:     make(chan bool),
+		closeOnce:    &sync.Once{},
+		chSockClosed: make(chan bool),
+		sockOnce:     &sync.Once{},
+	}
+
+	go o.read()
+	go o.expire()
+
+	return o, nil
+}
+
+//
+// Accept implements the method described by the net.Listener interface.
+//
+func (o *listener) Accept() (net.Conn, error) {
+	select {
+	case peer := <-o.chAccept:
+		return tcp.CreateMessageConn(peer), nil
+
+	case <-o.chClosed:
+		return nil, errListenerClosed
+	}
+}
+
+//
+// Close implements the method described by the net.Listener interface. Peers that have already been
+// accepted are left alone, and the underlying socket is closed once the last of them is.
+//
+func (o *listener) Close() error {
+	o.closeOnce.Do(func() {
+		close(o.chClosed)
+
+		o.mu.Lock()
+		o.closed = true
+		idle := len(o.peers) == 0
+		o.mu.Unlock()
+
+		if idle {
+			o.closeSocket()
+		}
+	})
+
+	return nil
+}
+
+//
+// Addr implements the method described by the net.Listener interface.
+//
+func (o *listener) Addr() net.Addr {
+	return o.conn.LocalAddr()
+}
+
+//
+// read reads datagrams from the socket until it is closed, routing each to the peer that sent it
+// and accepting new peers as they are seen.
+//
+func (o *listener) read() {
+	buf := make([]byte, maxDatagramSize)
+
+	for {
+		n, addr, err := o.conn.ReadFrom(buf)
+		if err != nil {
+			if realErr, ok := err.(net.Error); ok && realErr.Temporary() {
+				continue
+			}
+
+			//
+			// The socket is unusable (or was closed because the last peer went away), so nothing
+			// more can be received by anyone.
+			//
+			o.Close()
+			o.closePeers()
+
+			return
+		}
+
+		peer, isNew := o.peerFor(addr)
+
+		if peer == nil {
+			continue
+		}
+
+		if isNew {
+			select {
+			case o.chAccept <- peer:
+			case <-o.chClosed:
+				//
+				// Nobody will ever accept the peer, but we must keep reading on behalf of the peers
+				// that already were.
+				//
+				peer.Close()
+
+				continue
+			}
+		}
+
+		peer.deliver(buf[:n])
+	}
+}
+
+//
+// expire periodically closes peers that have not sent a datagram within the idle timeout.
+//
+func (o *listener) expire() {
+	ticker := time.NewTicker(o.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			var idle []*peerConn
+
+			o.mu.Lock()
+			for _, peer := range o.peers {
+				if now.Sub(peer.lastSeen()) > o.idleTimeout {
+					idle = append(idle, peer)
+				}
+			}
+			o.mu.Unlock()
+
+			for _, peer := range idle {
+				peer.expire()
+			}
+
+		case <-o.chSockClosed:
+			return
+		}
+	}
+}
+
+//
+// closeSocket closes the underlying socket (if it has not been already).
+//
+func (o *listener) closeSocket() {
+	o.sockOnce.Do(func() {
+		close(o.chSockClosed)
+
+		o.conn.Close()
+	})
+}
+
+//
+// closePeers closes every known peer.
+//
+func (o *listener) closePeers() {
+	o.mu.Lock()
+	peers := make([]*peerConn, 0, len(o.peers))
+	for _, peer := range o.peers {
+		peers = append(peers, peer)
+	}
+	o.mu.Unlock()
+
+	for _, peer := range peers {
+		peer.Close()
+	}
+}
+
+//
+// peerFor returns the peer associated with the provided remote address, creating it if it has not
+// been seen before. Once the listener has been closed (or while it already knows of as many peers as
+// it may), no new peers are created and nil is returned for unknown addresses.
+//
+func (o *listener) peerFor(addr net.Addr) (*peerConn, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key := addr.String()
+
+	if peer, ok := o.peers[key]; ok {
+		return peer, false
+	}
+
+	if o.closed || len(o.peers) >= o.maxPeers {
+		return nil, false
+	}
+
+	peer := &peerConn{
+		mu:       &sync.Mutex{},
+		listener: o,
+		addr:     addr,
+		seen:     time.Now(),
+		chRead:   make(chan []byte, peerQueueSize),
+		chClosed: make(chan bool),
+	}
+
+	o.peers[key] = peer
+
+	return peer, true
+}
+
+//
+// forgetPeer removes the provided peer from the peer table so that future datagrams from its
+// address are accepted as a brand new peer. If the listener has been closed and this was its last
+// peer, the underlying socket is closed.
+//
+func (o *listener) forgetPeer(peer *peerConn) {
+	o.mu.Lock()
+
+	key := peer.addr.String()
+
+	if o.peers[key] == peer {
+		delete(o.peers, key)
+	}
+
+	last := o.closed && len(o.peers) == 0
+
+	o.mu.Unlock()
+
+	if last {
+		o.closeSocket()
+	}
+}
+
+//
+// peerConn is a pseudo-connection representing a single remote peer of a UDP listener.
+//
+type peerConn struct {
+	mu           *sync.Mutex // Synchronizes access to the peer's mutable state.
+	listener     *listener   // The listener that the peer belongs to.
+	addr         net.Addr    // The remote address of the peer.
+	seen         time.Time   // When the peer last sent a datagram.
+	readDeadline time.Time   // The deadline for future reads, if any.
+	chRead       chan []byte // Channel holding datagrams that have been received but not yet read.
+	chClosed     chan bool   // Channel that is closed once the peer has been closed.
+	closed       bool        // Whether or not the peer has been closed.
+	expired      bool        // Whether or not the peer was closed because it went idle.
+}
+
+//
+// deliver queues a copy of the provided datagram to be read from the peer. If the peer's queue is
+// full, the datagram is dropped just as it might have been on the network.
+//
+func (o *peerConn) deliver(datagram []byte) {
+	o.mu.Lock()
+	o.seen = time.Now()
+	o.mu.Unlock()
+
+	select {
+	case o.chRead <- append([]byte(nil), datagram...):
+	default:
+	}
+}
+
+//
+// lastSeen returns when the peer last sent a datagram.
+//
+func (o *peerConn) lastSeen() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.seen
+}
+
+//
+// ReadMessage implements the method described by the tcp.MessageTransport interface. Each datagram
+// is read as exactly one message.
+//
+// NOTE: A read deadline only applies to reads that begin after it has been set.
+//
+func (o *peerConn) ReadMessage() ([]byte, error) {
+	o.mu.Lock()
+	deadline := o.readDeadline
+	o.mu.Unlock()
+
+	var chTimeout <-chan time.Time
+
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+
+		chTimeout = timer.C
+	}
+
+	select {
+	case datagram := <-o.chRead:
+		return datagram, nil
+
+	case <-o.chClosed:
+		//
+		// Report a peer that went idle as having timed out so that it is not mistaken for one that
+		// hung up (which UDP has no way of signalling anyway).
+		//
+		o.mu.Lock()
+		expired := o.expired
+		o.mu.Unlock()
+
+		if expired {
+			return nil, errTimeout
+		}
+
+		return nil, io.EOF
+
+	case <-chTimeout:
+		return nil, errTimeout
+	}
+}
+
+//
+// WriteMessage implements the method described by the tcp.MessageTransport interface. Each message
+// is sent as its own datagram.
+//
+func (o *peerConn) WriteMessage(msg []byte) error {
+	select {
+	case <-o.chClosed:
+		return io.ErrClosedPipe
+	default:
+	}
+
+	_, err := o.listener.conn.WriteTo(msg, o.addr)
+
+	return err
+}
+
+//
+// Read implements the method described by the net.Conn interface. Peers are only ever read from
+// through a tcp.MessageConn, which uses ReadMessage instead.
+//
+func (o *peerConn) Read(b []byte) (int, error) {
+	msg, err := o.ReadMessage()
+
+	return copy(b, msg), err
+}
+
+//
+// Write implements the method described by the net.Conn interface. Peers are only ever written to
+// through a tcp.MessageConn, which uses WriteMessage instead.
+//
+func (o *peerConn) Write(b []byte) (int, error) {
+	if err := o.WriteMessage(b); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+//
+// Close implements the method described by the net.Conn interface. The underlying socket is shared
+// with every other peer and is left open.
+//
+func (o *peerConn) Close() error {
+	o.mu.Lock()
+
+	if o.closed {
+		o.mu.Unlock()
+
+		return nil
+	}
+
+	o.closed = true
+
+	close(o.chClosed)
+
+	o.mu.Unlock()
+
+	//
+	// NOTE: We must not hold the peer's lock while taking the listener's, as the listener takes them
+	//  in the opposite order when looking for idle peers.
+	//
+	o.listener.forgetPeer(o)
+
+	return nil
+}
+
+//
+// expire closes the peer because it has not sent a datagram within the listener's idle timeout.
+// Subsequent reads fail with a timeout error rather than io.EOF.
+//
+func (o *peerConn) expire() {
+	o.mu.Lock()
+
+	if !o.closed {
+		o.expired = true
+	}
+
+	o.mu.Unlock()
+
+	o.Close()
+}
+
+//
+// LocalAddr implements the method described by the net.Conn interface.
+//
+func (o *peerConn) LocalAddr() net.Addr {
+	return o.listener.conn.LocalAddr()
+}
+
+//
+// RemoteAddr implements the method described by the net.Conn interface.
+//
+func (o *peerConn) RemoteAddr() net.Addr {
+	return o.addr
+}
+
+//
+// SetDeadline implements the method described by the net.Conn interface.
+//
+func (o *peerConn) SetDeadline(t time.Time) error {
+	return o.SetReadDeadline(t)
+}
+
+//
+// SetReadDeadline implements the method described by the net.Conn interface.
+//
+func (o *peerConn) SetReadDeadline(t time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.readDeadline = t
+
+	return nil
+}
+
+//
+// SetWriteDeadline implements the method described by the net.Conn interface. Writes to a UDP
+// socket do not block on the remote peer, so write deadlines are ignored.
+//
+func (o *peerConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+//
+// errTimeout is the error returned by reads that exceed their deadline.
+//
+var errTimeout net.Error = &timeoutError{}
+
+//
+// timeoutError is a net.Error describing an exceeded deadline.
+//
+type timeoutError struct{}
+
+//
+// Error implements the method described by the error interface.
+//
+func (o *timeoutError) Error() string {
+	return "i/o timeout"
+}
+
+//
+// Timeout implements the method described by the net.Error interface.
+//
+func (o *timeoutError) Timeout() bool {
+	return true
+}
+
+//
+// Temporary implements the method described by the net.Error interface.
+//
+func (o *timeoutError) Temporary() bool {
+	return true
+}
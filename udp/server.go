@@ -0,0 +1,70 @@
+package udp
+
+import (
+	"net"
+	"time"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+//
+// DefaultIdleTimeout is the amount of time after which a remote peer that has not sent any
+// datagrams is considered to have disconnected if no other timeout has been configured.
+//
+const DefaultIdleTimeout = 1 * time.Minute
+
+//
+// DefaultMaxPeers is the number of remote peers that may be tracked at once if no other limit has
+// been configured.
+//
+const DefaultMaxPeers = 4096
+
+//
+// ServerConfig holds various configuration attributes for creating a new UDP server. It embeds the
+// same configuration structure used by TCP/IP servers so that the same handler functions can be
+// registered with either.
+//
+// NOTE: Each datagram is treated as exactly one message, so the embedded "Delim" and "Framer"
+//  attributes are ignored.
+//
+type ServerConfig struct {
+	tcp.ServerConfig
+
+	IdleTimeout time.Duration // How long a remote peer may go without sending a datagram before it is disconnected. Defaults to DefaultIdleTimeout.
+	MaxPeers    int           // The maximum number of remote peers tracked at once. Datagrams from new addresses are dropped while it is reached. Defaults to DefaultMaxPeers.
+}
+
+//
+// Server holds info about an actual UDP server instance. Remote peers are tracked as pseudo-clients
+// (keyed by their address) that flow through the exact same lifecycle as TCP/IP clients, so the
+// embedded server's methods (e.g. SendAll) behave identically.
+//
+type Server struct {
+	*tcp.Server
+}
+
+//
+// CreateServer creates a new UDP server instance.
+//
+func CreateServer(config *ServerConfig) (*Server, error) {
+	tcp.LoggerFor(config.Logger).Info("Creating a UDP packet server.", "address", config.Address)
+
+	idleTimeout := config.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+
+	maxPeers := config.MaxPeers
+	if maxPeers <= 0 {
+		maxPeers = DefaultMaxPeers
+	}
+
+	server, err := tcp.CreateMessageServer(&config.ServerConfig, func(address string) (net.Listener, error) {
+		return listen(address, idleTimeout, maxPeers)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{Server: server}, nil
+}
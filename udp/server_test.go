@@ -0,0 +1,242 @@
+package udp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+const TestServerAddress = "localhost:9998"
+const TestMessage = "This is a test datagram.\nIt spans lines and has a \x00 in it."
+
+func TestBasicLifecycle(t *testing.T) {
+	//
+	// Define channels upon which we will state and assert proper functionality.
+	//
+	chNewClient := make(chan bool, 1)
+	chMessage := make(chan string, 1)
+	chConnectionClosed := make(chan bool, 1)
+	chDisconnected := make(chan tcp.DisconnectReason, 1)
+
+	//
+	// Create a new server with a short idle timeout so that we can watch the peer expire.
+	//
+	server, err := CreateServer(&ServerConfig{
+		ServerConfig: tcp.ServerConfig{
+			Address:     TestServerAddress,
+			OnNewClient: func(c *tcp.Client) { chNewClient <- true },
+			OnNewMessage: func(c *tcp.Client, message string) {
+				chMessage <- message
+
+				c.Send("pong")
+			},
+			OnClientConnectionClosed: func(c *tcp.Client) { chConnectionClosed <- true },
+			OnClientDisconnected:     func(c *tcp.Client, reason tcp.DisconnectReason) { chDisconnected <- reason },
+		},
+		IdleTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, err := server.Start()
+	if err != nil {
+		t.Fatalf("The server failed to start. (Error: %s)", err)
+	}
+
+	<-chStarted
+
+	//
+	// Send the server a test datagram and wait for the reply.
+	//
+	conn, err := net.Dial("udp", TestServerAddress)
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	conn.Write([]byte(TestMessage))
+
+	buf := make([]byte, 64)
+
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read a reply from the test server. (Error: %s)", err)
+	}
+
+	if string(buf[:n]) != "pong" {
+		t.Errorf("A reply was recieved, but it was not equal to what was expected. (Got: %q)", buf[:n])
+	}
+
+	//
+	// Assert that the server's handlers fired and that the expected messages came through.
+	//
+	select {
+	case <-chNewClient:
+	case <-time.After(1 * time.Second):
+		t.Error("The \"OnNewClient\" event handler never fired.")
+	}
+
+	select {
+	case message := <-chMessage:
+		if message != TestMessage {
+			t.Errorf("A message was recieved, but it was not equal to what was expected. (Got: %q)", message)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("The \"OnNewMessage\" event handler never fired.")
+	}
+
+	select {
+	case <-chConnectionClosed:
+	case <-time.After(1 * time.Second):
+		t.Error("The \"OnClientConnectionClosed\" event handler never fired after the peer went idle.")
+	}
+
+	select {
+	case reason := <-chDisconnected:
+		if reason != tcp.DisconnectIdleTimeout {
+			t.Errorf("An idle peer was disconnected for the wrong reason. (Got: %s)", reason)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("The \"OnClientDisconnected\" event handler never fired after the peer went idle.")
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestShutdownSaysGoodbye(t *testing.T) {
+	//
+	// Create a new server with a goodbye message that tells us when and why its peers disconnect.
+	//
+	chNewClient := make(chan bool, 1)
+	chDisconnected := make(chan tcp.DisconnectReason, 1)
+
+	server, err := CreateServer(&ServerConfig{
+		ServerConfig: tcp.ServerConfig{
+			Address:              "127.0.0.1:0",
+			GoodbyeMessage:       []byte("goodbye"),
+			OnNewClient:          func(c *tcp.Client) { chNewClient <- true },
+			OnClientDisconnected: func(c *tcp.Client, reason tcp.DisconnectReason) { chDisconnected <- reason },
+		},
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, err := server.Start()
+	if err != nil {
+		t.Fatalf("The server failed to start. (Error: %s)", err)
+	}
+
+	<-chStarted
+
+	conn, err := net.Dial("udp", server.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	conn.Write([]byte("hello"))
+
+	<-chNewClient
+
+	//
+	// Shut the server down gracefully and assert that the peer is told goodbye and disconnected for
+	// the right reason.
+	//
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("A graceful shutdown reported that it cut something off. (Error: %s)", err)
+	}
+
+	buf := make([]byte, 64)
+
+	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "goodbye" {
+		t.Errorf("The goodbye message was not delivered. (Got: %q) (Error: %v)", buf[:n], err)
+	}
+
+	select {
+	case reason := <-chDisconnected:
+		if reason != tcp.DisconnectServerShutdown {
+			t.Errorf("A peer was disconnected for the wrong reason. (Got: %s)", reason)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("The \"OnClientDisconnected\" event handler never fired.")
+	}
+}
+
+func TestMaxPeers(t *testing.T) {
+	//
+	// Create a new server that only tracks a single peer at a time and answers every datagram.
+	//
+	chNewClient := make(chan bool, 2)
+
+	server, err := CreateServer(&ServerConfig{
+		ServerConfig: tcp.ServerConfig{
+			Address:      "127.0.0.1:0",
+			OnNewClient:  func(c *tcp.Client) { chNewClient <- true },
+			OnNewMessage: func(c *tcp.Client, message string) { c.Send("pong") },
+		},
+		MaxPeers: 1,
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, err := server.Start()
+	if err != nil {
+		t.Fatalf("The server failed to start. (Error: %s)", err)
+	}
+
+	<-chStarted
+
+	//
+	// Send a datagram from two different addresses, and assert that only the first is answered.
+	//
+	buf := make([]byte, 64)
+
+	for i, expectReply := range []bool{true, false} {
+		conn, err := net.Dial("udp", server.Addr().String())
+		if err != nil {
+			t.Fatal("Failed to connect to the test server.")
+		}
+
+		defer conn.Close()
+
+		conn.Write([]byte("ping"))
+
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+
+		_, err = conn.Read(buf)
+		if expectReply && err != nil {
+			t.Errorf("A peer within the limit was not answered. (Peer: %d) (Error: %s)", i, err)
+		} else if !expectReply && err == nil {
+			t.Errorf("A peer beyond the limit was answered. (Peer: %d)", i)
+		}
+	}
+
+	if len(chNewClient) != 1 {
+		t.Errorf("The wrong number of peers were accepted. (Accepted: %d)", len(chNewClient))
+	}
+
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
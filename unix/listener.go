@@ -0,0 +1,271 @@
+package unix
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+//
+// maxPacketSize is the largest packet that will be read in one piece from a sequenced-packet
+// socket. Larger packets are truncated by the operating system.
+//
+const maxPacketSize = 65535
+
+//
+// staleSocketDialTimeout is how long to wait when probing an existing socket file to determine
+// whether or not another server is still listening on it.
+//
+const staleSocketDialTimeout = 1 * time.Second
+
+//
+// listener wraps a Unix domain socket listener so that the connections it accepts can be adapted
+// to the server's expectations.
+//
+type listener struct {
+	net.Listener
+
+	addr      *net.UnixAddr // The address that the socket file was moved to, or nil for an abstract socket.
+	seqPacket bool          // Whether or not the listener accepts sequenced-packet connections.
+}
+
+//
+// listen removes any stale socket file at the provided address, binds a new listener to it, and
+// then applies the requested permissions and ownership to the new socket file.
+//
+// NOTE: The socket is bound inside of a private directory and only moved to the provided address
+//  once its permissions and ownership have been applied, so that it is never reachable with the
+//  default ones.
+//
+func listen(
+	network string, address string, mode os.FileMode, uid int, gid int, logger tcp.Logger,
+) (net.Listener, error) {
+	seqPacket := network == "unixpacket"
+
+	if strings.HasPrefix(address, "@") {
+		ln, err := net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		return &listener{Listener: ln, seqPacket: seqPacket}, nil
+	}
+
+	if err := removeStaleSocket(network, address, logger); err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir(filepath.Dir(address), ".sock-")
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.RemoveAll(dir)
+
+	private := filepath.Join(dir, filepath.Base(address))
+
+	ln, err := net.Listen(network, private)
+	if err != nil {
+		return nil, err
+	}
+
+	//
+	// The listener would otherwise try to unlink the socket file at its private path when closed,
+	// which will no longer exist once it has been moved into place.
+	//
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := applyPermissions(private, mode, uid, gid); err != nil {
+		ln.Close()
+
+		return nil, err
+	}
+
+	if err := os.Rename(private, address); err != nil {
+		ln.Close()
+
+		return nil, err
+	}
+
+	return &listener{
+		Listener:  ln,
+		addr:      &net.UnixAddr{Name: address, Net: network},
+		seqPacket: seqPacket,
+	}, nil
+}
+
+//
+// Accept implements the method described by the net.Listener interface.
+//
+func (o *listener) Accept() (net.Conn, error) {
+	conn, err := o.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if o.seqPacket {
+		return tcp.CreateMessageConn(&packetConn{Conn: conn, buf: make([]byte, maxPacketSize)}), nil
+	}
+
+	return conn, nil
+}
+
+//
+// Close implements the method described by the net.Listener interface. The socket file is removed
+// along with the listener.
+//
+func (o *listener) Close() error {
+	err := o.Listener.Close()
+
+	if o.addr != nil {
+		os.Remove(o.addr.Name)
+	}
+
+	return err
+}
+
+//
+// Addr implements the method described by the net.Listener interface.
+//
+func (o *listener) Addr() net.Addr {
+	if o.addr != nil {
+		return o.addr
+	}
+
+	return o.Listener.Addr()
+}
+
+//
+// packetConn wraps a sequenced-packet connection so that each packet is read and written as exactly
+// one message. The operating system discards whatever part of a packet does not fit in the buffer
+// passed to a read, so each packet is read whole into an internal buffer.
+//
+type packetConn struct {
+	net.Conn
+
+	buf []byte // Buffer that each packet is read into.
+}
+
+//
+// ReadMessage implements the method described by the tcp.MessageTransport interface.
+//
+func (o *packetConn) ReadMessage() ([]byte, error) {
+	n, err := o.Conn.Read(o.buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.buf[:n], nil
+}
+
+//
+// WriteMessage implements the method described by the tcp.MessageTransport interface.
+//
+func (o *packetConn) WriteMessage(msg []byte) error {
+	_, err := o.Conn.Write(msg)
+
+	return err
+}
+
+//
+// removeStaleSocket removes a socket file left behind at the provided address by a server that is
+// no longer running. An error is returned if another server is still listening on the socket or if
+// something other than a socket exists at the address.
+//
+func removeStaleSocket(network string, address string, logger tcp.Logger) error {
+	info, err := os.Lstat(address)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("a file that is not a socket already exists at %s", address)
+	}
+
+	conn, err := net.DialTimeout(network, address, staleSocketDialTimeout)
+	if err == nil {
+		conn.Close()
+
+		return fmt.Errorf("another server is already listening on the socket at %s", address)
+	}
+
+	logger.Info("Removing a stale Unix domain socket file.", "address", address)
+
+	return os.Remove(address)
+}
+
+//
+// applyPermissions applies the provided permissions and ownership to the socket file at the
+// provided address. A zero mode or negative id leaves the corresponding attribute unchanged.
+//
+func applyPermissions(address string, mode os.FileMode, uid int, gid int) error {
+	if mode != 0 {
+		if err := os.Chmod(address, mode); err != nil {
+			return err
+		}
+	}
+
+	if uid >= 0 || gid >= 0 {
+		if err := os.Chown(address, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//
+// lookupOwnership resolves the provided user and group (each of which may be a name or a numeric
+// id) into numeric ids. An empty user or group resolves to -1, which leaves it unchanged.
+//
+func lookupOwnership(owner string, group string) (int, int, error) {
+	uid, gid := -1, -1
+
+	if len(owner) > 0 {
+		if _, err := strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			owner = u.Uid
+		}
+
+		id, err := strconv.Atoi(owner)
+		if err != nil {
+			return 0, 0, fmt.Errorf("the user %s does not have a numeric id", owner)
+		}
+
+		uid = id
+	}
+
+	if len(group) > 0 {
+		if _, err := strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			group = g.Gid
+		}
+
+		id, err := strconv.Atoi(group)
+		if err != nil {
+			return 0, 0, fmt.Errorf("the group %s does not have a numeric id", group)
+		}
+
+		gid = id
+	}
+
+	return uid, gid, nil
+}
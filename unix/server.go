@@ -0,0 +1,74 @@
+package unix
+
+import (
+	"net"
+	"os"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+//
+// ServerConfig holds various configuration attributes for creating a new Unix domain socket
+// server. It embeds the same configuration structure used by TCP/IP servers so that the same handler
+// functions can be registered with either. The embedded "Address" attribute holds the path of the
+// socket file (or, on Linux, an abstract socket name beginning with "@").
+//
+// NOTE: When using a sequenced-packet socket, each packet is treated as exactly one message, so the
+//  embedded "Delim" and "Framer" attributes are ignored.
+//
+type ServerConfig struct {
+	tcp.ServerConfig
+
+	SeqPacket bool        // Whether to use a sequenced-packet ("unixpacket") socket rather than a stream ("unix") socket.
+	Mode      os.FileMode // Permissions to apply to the socket file once it has been created. Zero leaves the default permissions in place.
+	Owner     string      // User name or numeric user id to make the owner of the socket file. Empty leaves the owner unchanged.
+	Group     string      // Group name or numeric group id to make the group of the socket file. Empty leaves the group unchanged.
+}
+
+//
+// Server holds info about an actual Unix domain socket server instance. Connections flow through
+// the exact same lifecycle as TCP/IP clients, so the embedded server's methods (e.g. SendAll)
+// behave identically.
+//
+type Server struct {
+	*tcp.Server
+}
+
+//
+// CreateServer creates a new Unix domain socket server instance.
+//
+func CreateServer(config *ServerConfig) (*Server, error) {
+	logger := tcp.LoggerFor(config.Logger)
+
+	logger.Info("Creating a Unix domain socket packet server.", "address", config.Address)
+
+	//
+	// Resolve the socket file's ownership up front so that a bad user or group name is reported now
+	// rather than every time the server is started.
+	//
+	uid, gid, err := lookupOwnership(config.Owner, config.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	network := "unix"
+	if config.SeqPacket {
+		network = "unixpacket"
+	}
+
+	listenFunc := func(address string) (net.Listener, error) {
+		return listen(network, address, config.Mode, uid, gid, logger)
+	}
+
+	createServer := tcp.CreateServerWithListener
+	if config.SeqPacket {
+		createServer = tcp.CreateMessageServer
+	}
+
+	server, err := createServer(&config.ServerConfig, listenFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{Server: server}, nil
+}
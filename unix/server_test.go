@@ -0,0 +1,101 @@
+package unix
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+const TestMessage = "This is a test message. Here is a number: 12345.67890!\x00"
+
+func TestBasicLifecycle(t *testing.T) {
+	for _, seqPacket := range []bool{false, true} {
+		network := "unix"
+		if seqPacket {
+			network = "unixpacket"
+		}
+
+		//
+		// Leave a stale socket file behind at the address that the server will bind to, just as a
+		// crashed server would have.
+		//
+		address := filepath.Join(t.TempDir(), "test.sock")
+
+		stale, err := net.Listen(network, address)
+		if err != nil {
+			t.Fatalf("Failed to create a stale socket file. (Error: %s)", err)
+		}
+
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		stale.Close()
+
+		//
+		// Create a new server and register event handlers that will report back what they see.
+		//
+		chMessage := make(chan string, 1)
+
+		server, err := CreateServer(&ServerConfig{
+			ServerConfig: tcp.ServerConfig{
+				Address:      address,
+				Delim:        '\x00',
+				OnNewMessage: func(c *tcp.Client, message string) { chMessage <- message },
+			},
+			SeqPacket: seqPacket,
+			Mode:      0600,
+		})
+		if err != nil {
+			t.Fatalf("The server failed to create. (Error: %s)", err)
+		}
+
+		chStarted, err := server.Start()
+		if err != nil {
+			t.Fatalf("The server failed to start over a stale socket file. (Error: %s)", err)
+		}
+
+		<-chStarted
+
+		//
+		// Assert that the requested permissions were applied to the socket file.
+		//
+		info, err := os.Stat(address)
+		if err != nil {
+			t.Fatalf("Failed to stat the socket file. (Error: %s)", err)
+		}
+
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("The socket file has permissions %s rather than the requested ones.", info.Mode().Perm())
+		}
+
+		//
+		// Connect to the server as a new client and send it a test message.
+		//
+		conn, err := net.Dial(network, address)
+		if err != nil {
+			t.Fatalf("Failed to connect to the test server. (Error: %s)", err)
+		}
+
+		conn.Write([]byte(TestMessage))
+
+		select {
+		case message := <-chMessage:
+			if message != TestMessage {
+				t.Errorf("A message was recieved, but it was not equal to what was expected. (Got: %q)", message)
+			}
+		case <-time.After(1 * time.Second):
+			t.Errorf("The \"OnNewMessage\" event handler never fired. (Network: %s)", network)
+		}
+
+		conn.Close()
+
+		//
+		// Tell the server to shutdown and then wait for it to finish.
+		//
+		chStopped, _ := server.Stop()
+
+		<-chStopped
+	}
+}
@@ -0,0 +1,419 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+//
+// Opcodes of the various WebSocket frame types, as described by RFC 6455.
+//
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+//
+// Status codes sent in close frames, as described by RFC 6455.
+//
+const (
+	closeNormal          = 1000
+	closeProtocolError   = 1002
+	closeInvalidPayload  = 1007
+	closeMessageTooLarge = 1009
+)
+
+//
+// closeTimeout is how long to wait when attempting to send a close frame to a client that may have
+// stopped reading.
+//
+const closeTimeout = 1 * time.Second
+
+//
+// errConnClosed is returned when attempting to write to a connection that has been closed.
+//
+var errConnClosed = errors.New("the WebSocket connection has been closed")
+
+//
+// closeError describes a condition that caused a WebSocket connection to be closed by the server.
+//
+type closeError struct {
+	code   uint16 // The status code that was sent to the client.
+	reason string // A description of why the connection was closed.
+}
+
+//
+// Error implements the method described by the error interface.
+//
+func (o *closeError) Error() string {
+	return fmt.Sprintf("websocket closed with status %d: %s", o.code, o.reason)
+}
+
+//
+// Is reports whether the close error is equivalent to the provided error so that the server can
+// tell why the connection was closed.
+//
+func (o *closeError) Is(target error) bool {
+	switch o.code {
+	case closeMessageTooLarge:
+		return target == tcp.ErrMessageTooLarge
+
+	case closeProtocolError, closeInvalidPayload:
+		return target == tcp.ErrProtocolViolation
+	}
+
+	return false
+}
+
+//
+// conn adapts an upgraded connection into a message transport that the server can read whole
+// messages from and write whole messages to. Control frames are handled transparently.
+//
+type conn struct {
+	net.Conn
+
+	reader    *bufio.Reader // Buffered reader holding anything read past the end of the opening handshake.
+	config    *ServerConfig // The configuration of the server that the connection belongs to.
+	writeMu   *sync.Mutex   // Serializes writes so that control frames do not interleave with messages.
+	closeSent bool          // Whether or not a close frame has been sent. Guarded by the write lock.
+	chClosed  chan bool     // Channel that is closed once the connection has been closed.
+	closeOnce *sync.Once    // Ensures that the connection is only closed once.
+}
+
+//
+// createConn instantiates and returns a new WebSocket connection around an upgraded connection.
+//
+func createConn(netConn net.Conn, reader *bufio.Reader, config *ServerConfig) *conn {
+	o := &conn{
+		Conn:      netConn,
+		reader:    reader,
+		config:    config,
+		writeMu:   &sync.Mutex{},
+		chClosed:  make(chan bool),
+		closeOnce: &sync.Once{},
+	}
+
+	if config.PingInterval > 0 {
+		go o.ping()
+	}
+
+	return o
+}
+
+//
+// ReadMessage implements the method described by the tcp.MessageTransport interface.
+//
+func (o *conn) ReadMessage() ([]byte, error) {
+	return o.readMessage()
+}
+
+//
+// WriteMessage implements the method described by the tcp.MessageTransport interface. Messages are
+// sent as text frames unless the server is configured to send binary ones.
+//
+func (o *conn) WriteMessage(msg []byte) error {
+	opcode := byte(opText)
+	if o.config.Binary {
+		opcode = opBinary
+	}
+
+	return o.writeFrame(opcode, msg)
+}
+
+//
+// Close implements the method described by the net.Conn interface. A close frame is sent to the
+// client (if one has not been already) before the underlying connection is closed.
+//
+func (o *conn) Close() error {
+	var err error
+
+	o.closeOnce.Do(func() {
+		close(o.chClosed)
+
+		o.Conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+		o.sendClose(closeNormal, "")
+
+		err = o.Conn.Close()
+	})
+
+	return err
+}
+
+//
+// LocalAddr implements the method described by the net.Conn interface. The returned address
+// reports its network as "ws" (or "wss") so that clients are described accurately.
+//
+func (o *conn) LocalAddr() net.Addr {
+	network := "ws"
+	if o.config.TLSConfig != nil {
+		network = "wss"
+	}
+
+	return &addr{Addr: o.Conn.LocalAddr(), network: network}
+}
+
+//
+// readMessage reads frames until a complete data message has been assembled, handling any control
+// frames that arrive along the way.
+//
+func (o *conn) readMessage() ([]byte, error) {
+	var msg []byte
+	var opcode byte
+
+	for {
+		fin, frameOpcode, pyld, err := o.readFrame()
+		if err != nil {
+			return nil, o.fail(err)
+		}
+
+		switch frameOpcode {
+		case opPing:
+			if err := o.writeFrame(opPong, pyld); err != nil {
+				return nil, err
+			}
+
+			continue
+
+		case opPong:
+			continue
+
+		case opClose:
+			code := uint16(closeNormal)
+			if len(pyld) >= 2 {
+				code = binary.BigEndian.Uint16(pyld)
+			}
+
+			o.sendClose(code, "")
+
+			return nil, io.EOF
+
+		case opText, opBinary:
+			if opcode != 0 {
+				return nil, o.fail(&closeError{closeProtocolError, "expected a continuation frame"})
+			}
+
+			opcode = frameOpcode
+			msg = pyld
+
+		case opContinuation:
+			if opcode == 0 {
+				return nil, o.fail(&closeError{closeProtocolError, "unexpected continuation frame"})
+			}
+
+			//
+			// Each frame has already been checked against the maximum message size on its own, but a
+			// fragmented message must also be checked as a whole before it is allowed to grow.
+			//
+			if len(msg)+len(pyld) > o.config.MaxMessageSize {
+				return nil, o.fail(&closeError{closeMessageTooLarge, "message too large"})
+			}
+
+			msg = append(msg, pyld...)
+
+		default:
+			return nil, o.fail(&closeError{closeProtocolError, "unknown opcode"})
+		}
+
+		if fin {
+			if opcode == opText && !utf8.Valid(msg) {
+				return nil, o.fail(&closeError{closeInvalidPayload, "text message is not valid UTF-8"})
+			}
+
+			return msg, nil
+		}
+	}
+}
+
+//
+// readFrame reads a single frame from the client, unmasking its payload.
+//
+func (o *conn) readFrame() (bool, byte, []byte, error) {
+	var header [8]byte
+
+	if _, err := io.ReadFull(o.reader, header[:2]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	size := uint64(header[1] & 0x7F)
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, &closeError{closeProtocolError, "reserved bits must not be set"}
+	}
+
+	if !masked {
+		return false, 0, nil, &closeError{closeProtocolError, "client frames must be masked"}
+	}
+
+	switch size {
+	case 126:
+		if _, err := io.ReadFull(o.reader, header[:2]); err != nil {
+			return false, 0, nil, err
+		}
+
+		size = uint64(binary.BigEndian.Uint16(header[:2]))
+
+	case 127:
+		if _, err := io.ReadFull(o.reader, header[:8]); err != nil {
+			return false, 0, nil, err
+		}
+
+		size = binary.BigEndian.Uint64(header[:8])
+	}
+
+	if opcode&0x8 != 0 && (size > 125 || !fin) {
+		return false, 0, nil, &closeError{closeProtocolError, "invalid control frame"}
+	}
+
+	//
+	// Never allocate a payload based on a length that has not been checked against the maximum
+	// message size, as the client fully controls it.
+	//
+	if size > uint64(o.config.MaxMessageSize) {
+		return false, 0, nil, &closeError{closeMessageTooLarge, "message too large"}
+	}
+
+	var mask [4]byte
+
+	if _, err := io.ReadFull(o.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	pyld := make([]byte, size)
+
+	if _, err := io.ReadFull(o.reader, pyld); err != nil {
+		return false, 0, nil, err
+	}
+
+	for i := range pyld {
+		pyld[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, pyld, nil
+}
+
+//
+// writeFrame writes a single, unfragmented frame to the client.
+//
+func (o *conn) writeFrame(opcode byte, pyld []byte) error {
+	o.writeMu.Lock()
+	defer o.writeMu.Unlock()
+
+	if o.closeSent {
+		return errConnClosed
+	}
+
+	return o.writeFrameLocked(opcode, pyld)
+}
+
+//
+// writeFrameLocked writes a single, unfragmented frame to the client. The caller must hold the
+// write lock.
+//
+func (o *conn) writeFrameLocked(opcode byte, pyld []byte) error {
+	frame := make([]byte, 0, 10+len(pyld))
+	frame = append(frame, 0x80|opcode)
+
+	switch {
+	case len(pyld) <= 125:
+		frame = append(frame, byte(len(pyld)))
+
+	case len(pyld) <= 0xFFFF:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(pyld)))
+
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(pyld)))
+	}
+
+	frame = append(frame, pyld...)
+
+	_, err := o.Conn.Write(frame)
+
+	return err
+}
+
+//
+// sendClose sends a close frame with the provided status code and reason to the client unless one
+// has already been sent.
+//
+func (o *conn) sendClose(code uint16, reason string) {
+	o.writeMu.Lock()
+	defer o.writeMu.Unlock()
+
+	if o.closeSent {
+		return
+	}
+
+	o.closeSent = true
+
+	pyld := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(pyld, code)
+	pyld = append(pyld, reason...)
+
+	o.writeFrameLocked(opClose, pyld)
+}
+
+//
+// fail sends a close frame describing the provided error if it is one that the server raised,
+// and then returns the error so that it can be passed along to the reader.
+//
+func (o *conn) fail(err error) error {
+	if closeErr, ok := err.(*closeError); ok {
+		o.sendClose(closeErr.code, closeErr.reason)
+	}
+
+	return err
+}
+
+//
+// ping periodically sends a ping frame to the client until the connection is closed.
+//
+func (o *conn) ping() {
+	ticker := time.NewTicker(o.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := o.writeFrame(opPing, nil); err != nil {
+				return
+			}
+
+		case <-o.chClosed:
+			return
+		}
+	}
+}
+
+//
+// addr wraps a network address in order to report a different network name for it.
+//
+type addr struct {
+	net.Addr
+
+	network string // The name of the network that the address should report.
+}
+
+//
+// Network implements the method described by the net.Addr interface.
+//
+func (o *addr) Network() string {
+	return o.network
+}
@@ -0,0 +1,233 @@
+package ws
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+//
+// acceptGUID is the globally unique identifier that RFC 6455 mixes into the opening handshake.
+//
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+//
+// errListenerClosed is returned by a listener's Accept method once it has been closed.
+//
+var errListenerClosed = errors.New("the WebSocket listener has been closed")
+
+//
+// listener wraps a TCP/IP listener and only hands out connections that have successfully completed
+// the WebSocket opening handshake. Handshakes are performed in their own goroutines so that a slow
+// client cannot hold up the acceptance of others.
+//
+type listener struct {
+	net.Listener
+
+	config    *ServerConfig // The configuration of the server that the listener belongs to.
+	chAccept  chan net.Conn // Channel used to hand upgraded connections to Accept.
+	chClosed  chan bool     // Channel that is closed once the listener has been closed.
+	closeOnce *sync.Once    // Ensures that the listener is only closed once.
+}
+
+//
+// listen binds a TCP/IP listener (secured with TLS if configured) to the provided address and
+// begins accepting and upgrading connections on it.
+//
+func listen(address string, config *ServerConfig) (net.Listener, error) {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.TLSConfig != nil {
+		ln = tls.NewListener(ln, config.TLSConfig)
+	}
+
+	o := &listener{
+		Listener:  ln,
+		config:    config,
+		chAccept:  make(chan net.Conn),
+		chClosed:  make(chan bool),
+		closeOnce: &sync.Once{},
+	}
+
+	go o.accept()
+
+	return o, nil
+}
+
+//
+// Accept implements the method described by the net.Listener interface.
+//
+func (o *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-o.chAccept:
+		return conn, nil
+
+	case <-o.chClosed:
+		return nil, errListenerClosed
+	}
+}
+
+//
+// Close implements the method described by the net.Listener interface.
+//
+func (o *listener) Close() error {
+	var err error
+
+	o.closeOnce.Do(func() {
+		close(o.chClosed)
+
+		err = o.Listener.Close()
+	})
+
+	return err
+}
+
+//
+// accept accepts raw connections until the listener is closed, upgrading each in a new goroutine.
+//
+func (o *listener) accept() {
+	for {
+		conn, err := o.Listener.Accept()
+		if err != nil {
+			if realErr, ok := err.(net.Error); ok && realErr.Temporary() {
+				time.Sleep(1 * time.Second)
+
+				continue
+			}
+
+			o.Close()
+
+			return
+		}
+
+		go o.upgrade(conn)
+	}
+}
+
+//
+// upgrade performs the server side of the WebSocket opening handshake on the provided connection
+// and, if it succeeds, hands the resulting WebSocket connection to Accept.
+//
+func (o *listener) upgrade(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(o.config.HandshakeTimeout))
+
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		conn.Close()
+
+		return
+	}
+
+	status, err := o.validate(req)
+	if err != nil {
+		o.config.Logger.Info("Rejected a WebSocket upgrade request.", "remote_addr", conn.RemoteAddr(), "error", err)
+
+		fmt.Fprintf(
+			conn,
+			"HTTP/1.1 %d %s\r\nConnection: close\r\nContent-Type: text/plain\r\nContent-Length: %d\r\n\r\n%s",
+			status, http.StatusText(status), len(err.Error()), err.Error(),
+		)
+
+		conn.Close()
+
+		return
+	}
+
+	_, err = fmt.Fprintf(
+		conn,
+		"HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: %s\r\n\r\n",
+		acceptKey(req.Header.Get("Sec-WebSocket-Key")),
+	)
+	if err != nil {
+		conn.Close()
+
+		return
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	wsConn := tcp.CreateMessageConn(createConn(conn, reader, o.config))
+
+	select {
+	case o.chAccept <- wsConn:
+	case <-o.chClosed:
+		wsConn.Close()
+	}
+}
+
+//
+// validate ensures that the provided request is a valid WebSocket upgrade request that the server
+// is willing to accept. If it is not, the HTTP status code that should be returned is provided
+// along with an error describing why.
+//
+func (o *listener) validate(req *http.Request) (int, error) {
+	if req.Method != http.MethodGet {
+		return http.StatusMethodNotAllowed, errors.New("upgrade requests must use the GET method")
+	}
+
+	if len(o.config.Path) > 0 && req.URL.Path != o.config.Path {
+		return http.StatusNotFound, fmt.Errorf("upgrades are not accepted on path %s", req.URL.Path)
+	}
+
+	if !headerContainsToken(req.Header, "Connection", "upgrade") ||
+		!headerContainsToken(req.Header, "Upgrade", "websocket") {
+		return http.StatusBadRequest, errors.New("the request is not a WebSocket upgrade request")
+	}
+
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		return http.StatusBadRequest, errors.New("only WebSocket protocol version 13 is supported")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(req.Header.Get("Sec-WebSocket-Key"))
+	if err != nil || len(key) != 16 {
+		return http.StatusBadRequest, errors.New("the request has an invalid WebSocket key")
+	}
+
+	if o.config.CheckOrigin != nil && !o.config.CheckOrigin(req) {
+		return http.StatusForbidden, errors.New("the request's origin is not allowed")
+	}
+
+	return http.StatusSwitchingProtocols, nil
+}
+
+//
+// acceptKey computes the value of the "Sec-WebSocket-Accept" response header for the provided
+// "Sec-WebSocket-Key" request header.
+//
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+//
+// headerContainsToken reports whether any of the comma-separated values of the provided header
+// are equal (ignoring case) to the provided token.
+//
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, candidate := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(candidate), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
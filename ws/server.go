@@ -0,0 +1,81 @@
+package ws
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+//
+// DefaultHandshakeTimeout is how long a new connection has to complete the WebSocket opening
+// handshake if no other timeout has been configured.
+//
+const DefaultHandshakeTimeout = 10 * time.Second
+
+//
+// DefaultMaxMessageSize is the largest message (and so the largest single frame) that a client may
+// send if no other maximum message size has been configured. Larger ones are refused with a 1009
+// close frame.
+//
+const DefaultMaxMessageSize = 1 << 20
+
+//
+// ServerConfig holds various configuration attributes for creating a new WebSocket server. It
+// embeds the same configuration structure used by TCP/IP servers so that the same handler functions
+// can be registered with either.
+//
+// NOTE: Each WebSocket message is treated as exactly one message, so the embedded "Delim" and
+//  "Framer" attributes are ignored. Text and binary messages are both delivered to the handlers.
+//  The embedded "HandshakeTimeout" attribute bounds the opening handshake and defaults to
+//  DefaultHandshakeTimeout. The embedded "MaxMessageSize" attribute defaults to
+//  DefaultMaxMessageSize rather than being unlimited.
+//
+type ServerConfig struct {
+	tcp.ServerConfig
+
+	Path         string                     // The request path on which upgrades are accepted. Empty accepts upgrades on any path.
+	CheckOrigin  func(r *http.Request) bool // Function deciding whether to accept an upgrade request based on its origin. Nil accepts all origins.
+	Binary       bool                       // Whether outbound messages are sent as binary frames rather than text frames.
+	PingInterval time.Duration              // How often to send a ping frame to each client. Zero disables pings.
+	TLSConfig    *tls.Config                // Secure connection configuration attributes for serving "wss://" connections. Nil serves plain "ws://".
+}
+
+//
+// Server holds info about an actual WebSocket server instance. Each WebSocket connection flows
+// through the exact same lifecycle as TCP/IP clients, so the embedded server's methods (e.g.
+// SendAll) behave identically.
+//
+type Server struct {
+	*tcp.Server
+}
+
+//
+// CreateServer creates a new WebSocket server instance.
+//
+func CreateServer(config *ServerConfig) (*Server, error) {
+	logger := tcp.LoggerFor(config.Logger)
+
+	logger.Info("Creating a WebSocket packet server.", "address", config.Address)
+
+	opts := *config
+	opts.Logger = logger
+	if opts.HandshakeTimeout <= 0 {
+		opts.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = DefaultMaxMessageSize
+	}
+
+	server, err := tcp.CreateMessageServer(&config.ServerConfig, func(address string) (net.Listener, error) {
+		return listen(address, &opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{Server: server}, nil
+}
@@ -0,0 +1,266 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lukehollenback/packet-server/tcp"
+)
+
+const TestServerAddress = "localhost:9997"
+const TestMessage = "This is a test message.\nIt spans lines."
+const TestKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+func TestBasicLifecycle(t *testing.T) {
+	//
+	// Define channels upon which we will state and assert proper functionality.
+	//
+	chMessage := make(chan string, 1)
+	chConnectionClosed := make(chan bool, 1)
+
+	//
+	// Create a new server that echoes every message it receives.
+	//
+	server, err := CreateServer(&ServerConfig{
+		ServerConfig: tcp.ServerConfig{
+			Address: TestServerAddress,
+			OnNewMessage: func(c *tcp.Client, message string) {
+				chMessage <- message
+
+				c.Send(message)
+			},
+			OnClientConnectionClosed: func(c *tcp.Client) { chConnectionClosed <- true },
+		},
+		Path: "/socket",
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, err := server.Start()
+	if err != nil {
+		t.Fatalf("The server failed to start. (Error: %s)", err)
+	}
+
+	<-chStarted
+
+	//
+	// Connect to the server and perform the opening handshake.
+	//
+	conn, err := net.Dial("tcp", TestServerAddress)
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(1 * time.Second))
+
+	io.WriteString(
+		conn,
+		"GET /socket HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Key: "+TestKey+"\r\nSec-WebSocket-Version: 13\r\n\r\n",
+	)
+
+	reader := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Failed to read the handshake response. (Error: %s)", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("The handshake was rejected. (Status: %d)", resp.StatusCode)
+	}
+
+	if resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("The handshake response had the wrong accept key. (Got: %s)", resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+
+	//
+	// Send a ping and a fragmented text message, and assert that the pong and the echoed message
+	// come back.
+	//
+	writeTestFrame(conn, true, opPing, []byte("hi"))
+	writeTestFrame(conn, false, opText, []byte(TestMessage[:10]))
+	writeTestFrame(conn, true, opContinuation, []byte(TestMessage[10:]))
+
+	if opcode, pyld := readTestFrame(t, reader); opcode != opPong || string(pyld) != "hi" {
+		t.Errorf("Expected a pong frame. (Opcode: %d) (Payload: %q)", opcode, pyld)
+	}
+
+	select {
+	case message := <-chMessage:
+		if message != TestMessage {
+			t.Errorf("A message was recieved, but it was not equal to what was expected. (Got: %q)", message)
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("The \"OnNewMessage\" event handler never fired.")
+	}
+
+	if opcode, pyld := readTestFrame(t, reader); opcode != opText || string(pyld) != TestMessage {
+		t.Errorf("Expected an echoed text frame. (Opcode: %d) (Payload: %q)", opcode, pyld)
+	}
+
+	//
+	// Perform the closing handshake.
+	//
+	writeTestFrame(conn, true, opClose, []byte{0x03, 0xE8})
+
+	if opcode, _ := readTestFrame(t, reader); opcode != opClose {
+		t.Errorf("Expected a close frame. (Opcode: %d)", opcode)
+	}
+
+	select {
+	case <-chConnectionClosed:
+	case <-time.After(1 * time.Second):
+		t.Error("The \"OnClientConnectionClosed\" event handler never fired.")
+	}
+
+	//
+	// Tell the server to shutdown and then wait for it to finish.
+	//
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestRejectsNonUpgradeRequest(t *testing.T) {
+	server, err := CreateServer(&ServerConfig{
+		ServerConfig: tcp.ServerConfig{Address: TestServerAddress},
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, err := server.Start()
+	if err != nil {
+		t.Fatalf("The server failed to start. (Error: %s)", err)
+	}
+
+	<-chStarted
+
+	resp, err := http.Get("http://" + TestServerAddress + "/")
+	if err != nil {
+		t.Fatalf("Failed to make a plain HTTP request. (Error: %s)", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("A plain HTTP request was not rejected. (Status: %d)", resp.StatusCode)
+	}
+
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+func TestRejectsOversizedFrame(t *testing.T) {
+	server, err := CreateServer(&ServerConfig{
+		ServerConfig: tcp.ServerConfig{Address: TestServerAddress},
+	})
+	if err != nil {
+		t.Fatalf("The server failed to create. (Error: %s)", err)
+	}
+
+	chStarted, err := server.Start()
+	if err != nil {
+		t.Fatalf("The server failed to start. (Error: %s)", err)
+	}
+
+	<-chStarted
+
+	conn, err := net.Dial("tcp", TestServerAddress)
+	if err != nil {
+		t.Fatal("Failed to connect to the test server.")
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(1 * time.Second))
+
+	io.WriteString(
+		conn,
+		"GET / HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Key: "+TestKey+"\r\nSec-WebSocket-Version: 13\r\n\r\n",
+	)
+
+	reader := bufio.NewReader(conn)
+
+	if _, err := http.ReadResponse(reader, nil); err != nil {
+		t.Fatalf("Failed to read the handshake response. (Error: %s)", err)
+	}
+
+	//
+	// Send the header of a masked frame claiming an absurd length, even though no maximum message
+	// size has been configured, and assert that the server refuses it rather than trying to allocate
+	// it.
+	//
+	header := []byte{0x80 | opBinary, 0x80 | 127, 0, 0, 0, 0, 0, 0, 0, 0, 0x12, 0x34, 0x56, 0x78}
+	binary.BigEndian.PutUint64(header[2:10], 1<<52)
+
+	conn.Write(header)
+
+	opcode, pyld := readTestFrame(t, reader)
+	if opcode != opClose || len(pyld) < 2 || binary.BigEndian.Uint16(pyld) != closeMessageTooLarge {
+		t.Errorf("Expected a \"message too large\" close frame. (Opcode: %d) (Payload: %q)", opcode, pyld)
+	}
+
+	chStopped, _ := server.Stop()
+
+	<-chStopped
+}
+
+//
+// writeTestFrame writes a masked frame to the server, just as a browser would.
+//
+func writeTestFrame(conn net.Conn, fin bool, opcode byte, pyld []byte) {
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+
+	frame := []byte{b0, 0x80 | byte(len(pyld))}
+	frame = append(frame, mask...)
+
+	for i, c := range pyld {
+		frame = append(frame, c^mask[i%4])
+	}
+
+	conn.Write(frame)
+}
+
+//
+// readTestFrame reads a single unmasked frame from the server.
+//
+func readTestFrame(t *testing.T, reader *bufio.Reader) (byte, []byte) {
+	header := make([]byte, 2)
+
+	if _, err := io.ReadFull(reader, header); err != nil {
+		t.Fatalf("Failed to read a frame header. (Error: %s)", err)
+	}
+
+	size := int(header[1] & 0x7F)
+
+	if size == 126 {
+		ext := make([]byte, 2)
+		io.ReadFull(reader, ext)
+		size = int(binary.BigEndian.Uint16(ext))
+	}
+
+	pyld := make([]byte, size)
+
+	if _, err := io.ReadFull(reader, pyld); err != nil {
+		t.Fatalf("Failed to read a frame payload. (Error: %s)", err)
+	}
+
+	return header[0] & 0x0F, pyld
+}